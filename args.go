@@ -0,0 +1,203 @@
+package gomem
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SplitArgs split s to arguments like shell
+// whitespace separates arguments, 'single quotes' are literal,
+// "double quotes" accept \" and \\,
+// outside quotes backslash escapes whitespace, quotes and backslash,
+// other backslash is kept for regexp and windows path
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var cur []rune
+	inArg := false
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, string(cur))
+				cur, inArg = nil, false
+			}
+		case r == '\'':
+			inArg = true
+			end := indexRune(rs, i+1, '\'')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated quote: %s", string(rs[i:]))
+			}
+			cur = append(cur, rs[i+1:end]...)
+			i = end
+		case r == '"':
+			inArg = true
+			j := i + 1
+			for ; j < len(rs) && rs[j] != '"'; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) && (rs[j+1] == '"' || rs[j+1] == '\\') {
+					j++
+				}
+				cur = append(cur, rs[j])
+			}
+			if j == len(rs) {
+				return nil, fmt.Errorf("unterminated quote: %s", string(rs[i:]))
+			}
+			i = j
+		case r == '\\' && i+1 < len(rs) && (unicode.IsSpace(rs[i+1]) || strings.ContainsRune(`'"\`, rs[i+1])):
+			inArg = true
+			cur = append(cur, rs[i+1])
+			i++
+		default:
+			inArg = true
+			cur = append(cur, r)
+		}
+	}
+	if inArg {
+		args = append(args, string(cur))
+	}
+	return args, nil
+}
+
+func indexRune(rs []rune, from int, r rune) int {
+	for i := from; i < len(rs); i++ {
+		if rs[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Flag option of Command
+type Flag struct {
+	Name  string // long name used as --name
+	Short string // short name used as -s, optional
+	Arg   string // name of value for usage, empty for bool flag
+	Usage string
+}
+
+// Command subcommand with parsed arguments, for SubCommands.AddCommand
+type Command struct {
+	Name    string
+	Args    string // usage of positional arguments e.g. "<key> [tag...]"
+	MinArgs int
+	MaxArgs int // -1 for unlimited
+	Flags   []Flag
+	Help    string
+	Run     func(*Args) (interface{}, error) // result for Renderer
+}
+
+// Args parsed arguments of Command
+type Args struct {
+	Args  []string
+	flags map[string][]string // key: Flag.Name
+}
+
+// Has return true if flag is given
+func (a *Args) Has(name string) bool {
+	_, ok := a.flags[name]
+	return ok
+}
+
+// Value return last value of flag, empty if not given
+func (a *Args) Value(name string) string {
+	values := a.flags[name]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// Values return all values of flag in given order
+func (a *Args) Values(name string) []string {
+	return a.flags[name]
+}
+
+// UsageError invalid arguments of Command
+type UsageError struct {
+	Cmd *Command
+	Msg string
+}
+
+func (e *UsageError) Error() string {
+	return e.Msg + "\n" + e.Cmd.Usage()
+}
+
+// Usage return usage and flags of c
+func (c *Command) Usage() string {
+	str := "usage: " + c.Name
+	if len(c.Flags) != 0 {
+		str += " [flags]"
+	}
+	if c.Args != "" {
+		str += " " + c.Args
+	}
+	for _, f := range c.Flags {
+		name := "--" + f.Name
+		if f.Short != "" {
+			name = "-" + f.Short + ", " + name
+		}
+		if f.Arg != "" {
+			name += " " + f.Arg
+		}
+		str += fmt.Sprintf("\n\t%s\t%s", name, f.Usage)
+	}
+	return str
+}
+
+// lookupFlag return flag by "--name" or "-short"
+// arg starting with "-" is flag only if c has flags
+func (c *Command) lookupFlag(arg string) (*Flag, bool) {
+	for i := range c.Flags {
+		f := &c.Flags[i]
+		if arg == "--"+f.Name || (f.Short != "" && arg == "-"+f.Short) {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Parse parse tokens to Args
+// flags are "--name", "-s", "--name value", "--name=value", "--" ends flags
+// if c has no flags then "-x" is positional argument
+func (c *Command) Parse(tokens []string) (*Args, error) {
+	a := &Args{flags: make(map[string][]string)}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if len(c.Flags) == 0 || len(tok) < 2 || tok[0] != '-' {
+			a.Args = append(a.Args, tok)
+			continue
+		}
+		if tok == "--" {
+			a.Args = append(a.Args, tokens[i+1:]...)
+			break
+		}
+		name, value, hasValue := tok, "", false
+		if j := strings.Index(tok, "="); j != -1 && strings.HasPrefix(tok, "--") {
+			name, value, hasValue = tok[:j], tok[j+1:], true
+		}
+		f, ok := c.lookupFlag(name)
+		if !ok {
+			return nil, &UsageError{Cmd: c, Msg: "unknown flag: " + name}
+		}
+		switch {
+		case f.Arg == "" && hasValue:
+			return nil, &UsageError{Cmd: c, Msg: "flag does not take value: " + name}
+		case f.Arg != "" && !hasValue:
+			if i+1 == len(tokens) {
+				return nil, &UsageError{Cmd: c, Msg: "flag requires value: " + name}
+			}
+			i++
+			value = tokens[i]
+		}
+		a.flags[f.Name] = append(a.flags[f.Name], value)
+	}
+	switch {
+	case len(a.Args) < c.MinArgs:
+		return nil, &UsageError{Cmd: c, Msg: fmt.Sprintf("require %d arguments but got %d", c.MinArgs, len(a.Args))}
+	case c.MaxArgs >= 0 && len(a.Args) > c.MaxArgs:
+		return nil, &UsageError{Cmd: c, Msg: fmt.Sprintf("accept at most %d arguments but got %d", c.MaxArgs, len(a.Args))}
+	}
+	return a, nil
+}
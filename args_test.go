@@ -0,0 +1,124 @@
+package gomem
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "  a  b\tc ", want: []string{"a", "b", "c"}},
+		{in: `"buy milk" eggs`, want: []string{"buy milk", "eggs"}},
+		{in: `'it''s' x`, want: []string{"its", "x"}},
+		{in: `"say \"hi\"" 'a\b'`, want: []string{`say "hi"`, `a\b`}},
+		{in: `a\ b \"q\" \\`, want: []string{"a b", `"q"`, `\`}},
+		{in: `^egg\w+ C:\memo`, want: []string{`^egg\w+`, `C:\memo`}},
+		{in: `pre"fix"post ''`, want: []string{"prefixpost", ""}},
+		{in: `"unterminated`, wantErr: true},
+		{in: `it's`, wantErr: true},
+	}
+	for _, v := range tests {
+		got, err := SplitArgs(v.in)
+		if v.wantErr {
+			if err == nil {
+				t.Errorf("%q: want error but got %q", v.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", v.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%q: want %q but got %q", v.in, v.want, got)
+		}
+	}
+}
+
+func TestCommand_Parse(t *testing.T) {
+	c := &Command{
+		Name: "todo", Args: "[name]", MaxArgs: 1,
+		Flags: []Flag{
+			{Name: "due", Arg: "date"},
+			{Name: "filter", Arg: "field:value"},
+			{Name: "all", Short: "a"},
+		},
+	}
+	tests := []struct {
+		in      []string
+		args    []string
+		flags   map[string][]string
+		wantErr bool
+	}{
+		{in: nil, flags: map[string][]string{}},
+		{in: []string{"x", "--due", "today"}, args: []string{"x"}, flags: map[string][]string{"due": {"today"}}},
+		{in: []string{"--due=+3d", "-a", "x"}, args: []string{"x"}, flags: map[string][]string{"due": {"+3d"}, "all": {""}}},
+		{in: []string{"--filter", "tag:a", "--filter", "status:open"}, flags: map[string][]string{"filter": {"tag:a", "status:open"}}},
+		{in: []string{"--", "-x"}, args: []string{"-x"}, flags: map[string][]string{}},
+		{in: []string{"-"}, args: []string{"-"}, flags: map[string][]string{}},
+		{in: []string{"--unknown"}, wantErr: true},
+		{in: []string{"--due"}, wantErr: true},
+		{in: []string{"--all=yes"}, wantErr: true},
+		{in: []string{"a", "b"}, wantErr: true},
+	}
+	for _, v := range tests {
+		a, err := c.Parse(v.in)
+		if v.wantErr {
+			if _, ok := err.(*UsageError); !ok {
+				t.Errorf("%q: want *UsageError but got %v", v.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", v.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(a.Args, v.args) || !reflect.DeepEqual(a.flags, v.flags) {
+			t.Errorf("%q: want %q %q but got %q %q", v.in, v.args, v.flags, a.Args, a.flags)
+		}
+	}
+
+	// without flags, "-x" is argument
+	tag := &Command{Name: "tag", MinArgs: 1, MaxArgs: -1}
+	a, err := tag.Parse([]string{"key", "-old", "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"key", "-old", "new"}; !reflect.DeepEqual(a.Args, want) {
+		t.Errorf("want %q but got %q", want, a.Args)
+	}
+	if _, err := tag.Parse(nil); err == nil {
+		t.Error("want error for missing argument")
+	}
+}
+
+func TestArgs_Value(t *testing.T) {
+	a := &Args{flags: map[string][]string{"f": {"1", "2"}, "b": {""}}}
+	if !a.Has("b") || a.Has("x") {
+		t.Error("invalid Has")
+	}
+	if a.Value("f") != "2" || a.Value("x") != "" {
+		t.Errorf("invalid Value: %q", a.Value("f"))
+	}
+	if !reflect.DeepEqual(a.Values("f"), []string{"1", "2"}) {
+		t.Errorf("invalid Values: %q", a.Values("f"))
+	}
+}
+
+func TestCommand_Usage(t *testing.T) {
+	c := &Command{
+		Name: "search", Args: "<query>...",
+		Flags: []Flag{{Name: "regexp", Short: "e", Usage: "regular expression"}, {Name: "sort", Arg: "by", Usage: "order"}},
+	}
+	want := "usage: search [flags] <query>...\n" +
+		"\t-e, --regexp\tregular expression\n" +
+		"\t--sort by\torder"
+	if got := c.Usage(); got != want {
+		t.Errorf("want:\n%s\nbut got:\n%s", want, got)
+	}
+}
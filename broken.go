@@ -0,0 +1,104 @@
+package gomem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Tolerant if true then IncludeJSON records files failed to load to Gomems.Broken
+// instead of return error, and GomemsNew returns Gomems with *LoadError
+var Tolerant = false
+
+// Broken file that failed to parse or load, excluded from Gmap and writes
+type Broken struct {
+	Raw      []byte
+	Err      error
+	Line     int // position of Err, 0 if unknown
+	Column   int
+	fullpath string
+	disk     *diskState
+}
+
+func (b *Broken) Error() string {
+	if b.Line == 0 {
+		return b.Err.Error()
+	}
+	return fmt.Sprintf("%v (line %d, column %d)", b.Err, b.Line, b.Column)
+}
+
+// GetFullpath return fullpath of broken file
+func (b *Broken) GetFullpath() string {
+	return b.fullpath
+}
+
+// newBroken read fullpath and set position of err
+func newBroken(fullpath string, err error) (*Broken, error) {
+	info, serr := os.Stat(fullpath)
+	if serr != nil {
+		return nil, serr
+	}
+	raw, rerr := ioutil.ReadFile(fullpath)
+	if rerr != nil {
+		return nil, rerr
+	}
+	b := &Broken{Raw: raw, fullpath: fullpath, disk: newDiskState(info, raw)}
+	b.setErr(err)
+	return b, nil
+}
+
+func (b *Broken) setErr(err error) {
+	b.Err = err
+	b.Line, b.Column = 0, 0
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		// Offset is after the invalid character
+		offset = e.Offset - 1
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return
+	}
+	if offset < 0 {
+		offset = 0
+	} else if offset > int64(len(b.Raw)) {
+		offset = int64(len(b.Raw))
+	}
+	before := string(b.Raw[:offset])
+	b.Line = strings.Count(before, "\n") + 1
+	b.Column = len(before) - strings.LastIndex(before, "\n")
+}
+
+// BrokenKeys return sorted keys of gs.Broken
+func (gs *Gomems) BrokenKeys() []string {
+	var keys []string
+	for key := range gs.Broken {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Repair parse raw as content of gs.Broken[key]
+// if valid then move to gs.Gmap as dirty Gomem, for write by WriteFile
+// else update gs.Broken[key] and return *Broken
+func (gs *Gomems) Repair(key string, raw []byte) (*Gomem, error) {
+	b, ok := gs.Broken[key]
+	if !ok {
+		return nil, fmt.Errorf("*Gomems.Repair: not found gs.Broken[%s]", key)
+	}
+	j, version, err := decodeJSON(raw, key)
+	if err != nil {
+		b.Raw = raw
+		b.setErr(err)
+		return nil, b
+	}
+	g := &Gomem{J: j, Override: true, fullpath: b.fullpath, dirty: true, disk: b.disk, version: version, owner: gs}
+	delete(gs.Broken, key)
+	gs.Gmap[key] = g
+	return g, nil
+}
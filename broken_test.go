@@ -0,0 +1,127 @@
+package gomem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGomems_Broken(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"ok.json":     `{"title": "ok", "content": []}`,
+		"broken.json": "{\n  \"title\": \"broken\",\n  \"content\": [\n    \"a\"\n    \"b\"\n  ]\n}",
+		"type.json":   `{"title": 1}`,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := GomemsNew(dir); err == nil {
+		t.Fatal("want error if not Tolerant")
+	}
+
+	defer func(b bool) { Tolerant = b }(Tolerant)
+	Tolerant = true
+	gs, err := GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.Close()
+	if len(gs.Gmap) != 1 || gs.Gmap["ok.json"] == nil {
+		t.Fatalf("want only ok.json in Gmap but got %v", gs.Gmap)
+	}
+	if keys := gs.BrokenKeys(); len(keys) != 2 || keys[0] != "broken.json" || keys[1] != "type.json" {
+		t.Fatalf("unexpected broken keys: %q", keys)
+	}
+	if b := gs.Broken["broken.json"]; b.Line != 5 || b.Column != 5 {
+		t.Errorf("want line 5 column 5 but got %v", b)
+	}
+	if keys := gs.Dirty(); len(keys) != 0 {
+		t.Errorf("broken files is not to write: %q", keys)
+	}
+	g, err := New(filepath.Join(dir, "broken.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.AddGomem(g); err == nil {
+		t.Error("want error for add over broken file")
+	}
+
+	// repair
+	if _, err := gs.Repair("broken.json", []byte(`{"title": "still`)); err == nil {
+		t.Fatal("want error for invalid json")
+	}
+	if string(gs.Broken["broken.json"].Raw) != `{"title": "still` {
+		t.Error("raw is not updated")
+	}
+	g, err = gs.Repair("broken.json", []byte(`{"title": "repaired", "content": ["a", "b"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gs.Broken["broken.json"]; ok {
+		t.Error("repaired file is left in Broken")
+	}
+	if gs.Gmap["broken.json"] != g || !g.IsDirty() {
+		t.Fatal("repaired file is not in Gmap as dirty")
+	}
+	if err := g.WriteFile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.IncludeJSON(); err != nil {
+		t.Fatal(err)
+	}
+	if len(gs.Broken) != 1 || gs.Gmap["broken.json"].J.Title != "repaired" {
+		t.Errorf("unexpected after reinclude: broken:%v title:%q", gs.BrokenKeys(), gs.Gmap["broken.json"].J.Title)
+	}
+}
+
+func TestGomemsNew_TolerantLoadError(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "loaderror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"ok.json":    `{"title": "ok"}`,
+		"newer.json": `{"version": 9}`,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "nothing"), filepath.Join(dir, "dangling.json")); err != nil {
+		t.Fatal(err)
+	}
+	defer func(b, f bool) { Tolerant, FollowSymlinks = b, f }(Tolerant, FollowSymlinks)
+	FollowSymlinks = true
+
+	Tolerant = false
+	if gs, err := GomemsNew(dir); err == nil || gs != nil {
+		t.Fatalf("want only error if not Tolerant but got %v %v", gs, err)
+	}
+
+	// readable file is broken, others are *LoadError with loaded gs
+	Tolerant = true
+	gs, err := GomemsNew(dir)
+	if gs == nil {
+		t.Fatalf("want partly loaded gs but got %v", err)
+	}
+	defer gs.Close()
+	lerr, ok := err.(*LoadError)
+	if !ok || len(lerr.Errs) != 1 || lerr.Errs[0].Path != filepath.Join(dir, "dangling.json") {
+		t.Errorf("want *LoadError of dangling.json but got %v", err)
+	}
+	if len(gs.Gmap) != 1 || gs.Gmap["ok.json"] == nil {
+		t.Errorf("want only ok.json in Gmap but got %v", gs.Gmap)
+	}
+	if keys := gs.BrokenKeys(); len(keys) != 1 || keys[0] != "newer.json" {
+		t.Errorf("want newer.json in Broken but got %q", keys)
+	}
+}
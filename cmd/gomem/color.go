@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/kamisari/gomem/lineedit"
+)
+
+// color of roles, changed by [theme] in conf
+var (
+	keyString     = color.GreenString
+	titleString   = color.MagentaString
+	contentString = color.CyanString
+)
+
+// colorAttrs names of colors and attributes for theme, "none" is no color
+var colorAttrs = map[string]color.Attribute{
+	"black":     color.FgBlack,
+	"red":       color.FgRed,
+	"green":     color.FgGreen,
+	"yellow":    color.FgYellow,
+	"blue":      color.FgBlue,
+	"magenta":   color.FgMagenta,
+	"cyan":      color.FgCyan,
+	"white":     color.FgWhite,
+	"hiblack":   color.FgHiBlack,
+	"hired":     color.FgHiRed,
+	"higreen":   color.FgHiGreen,
+	"hiyellow":  color.FgHiYellow,
+	"hiblue":    color.FgHiBlue,
+	"himagenta": color.FgHiMagenta,
+	"hicyan":    color.FgHiCyan,
+	"hiwhite":   color.FgHiWhite,
+	"bold":      color.Bold,
+	"faint":     color.Faint,
+	"italic":    color.Italic,
+	"underline": color.Underline,
+}
+
+// colorNames return sorted names of colorAttrs and none
+func colorNames() []string {
+	names := []string{"none"}
+	for name := range colorAttrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseColor return sprintf of "color[,attribute...]"
+func parseColor(s string) (func(string, ...interface{}) string, error) {
+	if s == "none" {
+		return fmt.Sprintf, nil
+	}
+	var attrs []color.Attribute
+	for _, name := range strings.Split(s, ",") {
+		attr, ok := colorAttrs[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid color: %q: require %s", name, strings.Join(colorNames(), ", "))
+		}
+		attrs = append(attrs, attr)
+	}
+	return color.New(attrs...).SprintfFunc(), nil
+}
+
+// initColor resolve -color by flag, conf and NO_COLOR, and apply [theme] in conf
+func (opt *option) initColor() error {
+	if opt.color == "" {
+		if list := opt.getConf("color"); len(list) != 0 {
+			opt.color = list[len(list)-1]
+		}
+	}
+	switch opt.color {
+	case "", "auto":
+		opt.color = "auto"
+		textNoColor = os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !lineedit.IsTerminal(os.Stdout)
+	case "always":
+		textNoColor = false
+	case "never":
+		textNoColor = true
+	default:
+		return fmt.Errorf("invalid color: %q: require auto, always or never", opt.color)
+	}
+	color.NoColor = textNoColor
+	for _, role := range []struct {
+		name string
+		f    *func(string, ...interface{}) string
+	}{{"key", &keyString}, {"title", &titleString}, {"content", &contentString}} {
+		list := opt.getSection("theme", role.name)
+		if len(list) == 0 {
+			continue
+		}
+		f, err := parseColor(list[len(list)-1])
+		if err != nil {
+			return fmt.Errorf("theme: %s: %v", role.name, err)
+		}
+		*role.f = f
+	}
+	prefname = keyString("filename:> ")
+	pretitle = titleString("title:> ")
+	precontent = contentString("content:> ")
+	return nil
+}
@@ -6,58 +6,109 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/kamisari/gomem"
+	"github.com/kamisari/gomem/lineedit"
 )
 
 // for Read and confirm
 // accept exchange output and input
-// default: writer = os.Stdout
-//        : reader = os.Stdin
+// default: writer = os.Stdout, reader = os.Stdin
 var (
-	igs         *gomem.Gomems
-	interWriter io.Writer = os.Stdout
-	interReader io.Reader = os.Stdin
+	igs            *gomem.Gomems
+	interWriter    io.Writer      = os.Stdout
+	interReader    io.Reader      = os.Stdin
+	interErrWriter io.Writer      = os.Stderr // errors of script with set +e
+	ihistory       *gomem.History             // record read() if opt.histPrompts
+	startDir       string                     // working directory before chdir to workdir, for run
+	noPrompt       bool                       // input is here-document of script or stdin of one-shot, prompts are not printed
 )
 
+// scanner of interReader, shared for input buffered by previous read
 var (
-	prefname   = color.GreenString("filename:> ")
-	pretitle   = color.MagentaString("title:> ")
-	precontent = color.CyanString("content:> ")
+	inputReader  io.Reader
+	inputScanner *bufio.Scanner
+)
+
+// interInput return scanner of interReader
+func interInput() *bufio.Scanner {
+	if inputReader != interReader || inputScanner == nil {
+		inputReader = interReader
+		inputScanner = bufio.NewScanner(interReader)
+	}
+	return inputScanner
+}
+
+// interReadLine read line of interactive session, set by interactive
+// prompts share buffered input and line editor with Repl by this
+var interReadLine func(prompt string) (string, error)
+
+// readLine print prompt msg and read answer, false if no answer
+// by interReadLine in interactive session, else by scanner of interReader
+// prompt is not printed in script and one-shot
+func readLine(msg string) (string, bool) {
+	if interReadLine != nil && !noPrompt {
+		line, err := interReadLine(msg)
+		return line, err == nil
+	}
+	if !noPrompt {
+		fmt.Fprint(noticeWriter(), msg)
+	}
+	sc := interInput()
+	if !sc.Scan() {
+		return "", false
+	}
+	return sc.Text(), true
+}
+
+// noticeWriter return writer of prompts and notices besides result, e.g. diff for prompt
+// stderr if prompts are not printed or format is not text, not to mix with result
+func noticeWriter() io.Writer {
+	if noPrompt || opt.format != "text" {
+		return interErrWriter
+	}
+	return interWriter
+}
+
+// errCanceled declined confirm or no answer of prompt
+var errCanceled = gomem.Failf("canceled")
+
+// prompts of read, colored by initColor
+var (
+	prefname   = "filename:> "
+	pretitle   = "title:> "
+	precontent = "content:> "
 )
 
 // simple read
 func read(msg string) string {
-	fmt.Print(msg)
-	sc := bufio.NewScanner(interReader)
-	sc.Scan()
-	if sc.Err() != nil {
-		panic(sc.Err())
+	line, _ := readLine(msg)
+	if opt.histPrompts && ihistory != nil {
+		ihistory.Add(line)
 	}
-	return sc.Text()
+	return line
 }
 
-// simple confirm
+// simple confirm, false if declined or no answer
 func confirm(msg string) bool {
-	fmt.Fprint(interWriter, msg+" [yes:no]?>")
-	for sc, i := bufio.NewScanner(interReader), 0; sc.Scan() && i < 2; i++ {
-		if sc.Err() != nil {
-			panic(sc.Err)
+	for i := 0; i < 2; i++ {
+		line, ok := readLine(msg + " [yes:no]?>")
+		if !ok {
+			return false
 		}
-		switch sc.Text() {
+		switch line {
 		case "yes", "y":
 			return true
 		case "no", "n":
 			return false
-		default:
-			fmt.Fprintln(interWriter, sc.Text())
-			fmt.Fprint(interWriter, msg+" [yes:no]?>")
 		}
 	}
 	return false
@@ -70,298 +121,1201 @@ func path2json(s *string) {
 	}
 }
 
-/// commands ///
-// status //
-func la() (string, error) {
-	var str string
-	for key, v := range igs.Gmap {
-		str += color.GreenString("----- %s -----\n", key)
-		str += color.MagentaString("[ %s ]\n", v.J.Title)
-		str += color.CyanString("%s\n", strings.Join(v.J.Content, "\n"))
+// relKey return key of fpath in igs
+func relKey(fpath string) string {
+	key, err := filepath.Rel(igs.GetDir(), fpath)
+	if err != nil {
+		return fpath
 	}
-	return str, nil
+	return key
 }
-func ls() (string, error) {
-	var str string
+
+// lookupKey resolve s to key by fuzzy matching, filter is passed to Lookup
+// if not resolved then return *gomem.CommandError
+// ambiguous candidates are picked by number
+func lookupKey(s string, filter func(string, *gomem.Gomem) bool) (string, error) {
+	m := igs.Lookup(s, filter)
+	switch {
+	case m.Key != "":
+		return m.Key, nil
+	case len(m.Candidates) != 0:
+		return pickKey(s, m.Candidates)
+	case len(m.Suggestions) != 0:
+		var keys []string
+		for _, key := range m.Suggestions {
+			keys = append(keys, keyString(key))
+		}
+		return "", gomem.Failf("not found:%s\ndid you mean: %s", keyString(s), strings.Join(keys, ", "))
+	}
+	return "", gomem.Failf("not found:%s", keyString(s))
+}
+
+// pickKey select one of candidates, empty or invalid number is errCanceled
+func pickKey(s string, candidates []string) (string, error) {
+	msg := "ambiguous:" + keyString(s) + "\n"
+	for i, key := range candidates {
+		msg += fmt.Sprintf("%d: ", i+1) + keyString(key) + titleString("[ %s ]\n", igs.Gmap[key].J.Title)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(read(msg + "pick number:> ")))
+	if err != nil || n < 1 || n > len(candidates) {
+		return "", errCanceled
+	}
+	return candidates[n-1], nil
+}
+
+// isTodo filter of lookupKey
+func isTodo(_ string, g *gomem.Gomem) bool {
+	return g.IsTodo()
+}
+
+// completion kinds of argument
+var (
+	completeKeys     = []string{"show", "info", "tag", "rm", "rmcache", "mod", "readonly!"}
+	completeTodos    = []string{"done", "start", "cancel", "reopen", "append", "trim", "check", "due", "remind", "priority", "repeat"}
+	completeCategory = []string{"new", "rmsub"}
+	completeOrders   = []string{"la", "ls", "state"}
+)
+
+// completeArg return candidates of first argument for SubCommands.Complete
+func completeArg(cmd, arg string) []string {
+	if strings.Contains(arg, " ") {
+		return nil
+	}
+	has := func(list []string) bool {
+		for _, s := range list {
+			if s == cmd {
+				return true
+			}
+		}
+		return false
+	}
+	var words []string
+	switch {
+	case has(completeKeys):
+		for key := range igs.Gmap {
+			words = append(words, key)
+		}
+	case has(completeTodos):
+		prefix := "todo" + string(filepath.Separator)
+		for key, g := range igs.Gmap {
+			if g.IsTodo() && strings.HasPrefix(key, prefix) {
+				words = append(words, strings.TrimSuffix(strings.TrimPrefix(key, prefix), ".json"))
+			}
+		}
+	case has(completeCategory):
+		words = subcategories()
+	case has(completeOrders):
+		words = gomem.KeySorts
+	case cmd == "repair":
+		words = igs.BrokenKeys()
+	case cmd == "format":
+		words = gomem.Formats()
+	case cmd == "run":
+		words = scriptFiles(arg)
+	}
+	var candidates []string
+	for _, w := range words {
+		if strings.HasPrefix(w, arg) {
+			candidates = append(candidates, w)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// scriptFiles return files in directory of arg for run, directory end with separator
+func scriptFiles(arg string) []string {
+	dir := arg[:strings.LastIndex(arg, string(filepath.Separator))+1]
+	base := dir
+	if !filepath.IsAbs(dir) {
+		base = filepath.Join(startDir, dir)
+	}
+	infos, err := ioutil.ReadDir(base)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, info := range infos {
+		name := dir + info.Name()
+		if info.IsDir() {
+			name += string(filepath.Separator)
+		}
+		files = append(files, name)
+	}
+	return files
+}
+
+// subcategories return directories in igs, end with separator
+func subcategories() []string {
+	dirs := make(map[string]bool)
 	for key := range igs.Gmap {
-		str += color.GreenString("%s\n", key)
+		for dir := filepath.Dir(key); dir != "."; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
 	}
-	return str, nil
+	if infos, err := ioutil.ReadDir(igs.GetDir()); err == nil {
+		for _, info := range infos {
+			if info.IsDir() && !strings.HasPrefix(info.Name(), ".") {
+				dirs[info.Name()] = true
+			}
+		}
+	}
+	var list []string
+	for dir := range dirs {
+		list = append(list, dir+string(filepath.Separator))
+	}
+	return list
 }
-func state() (string, error) {
-	var str string
-	str += color.GreenString("igs.dir:%s\n", igs.GetDir())
+
+// commands //
+// status //
+// parseOrder parse "[key|title|modified|created] [--reverse]"
+func parseOrder(s string) (by string, reverse bool, err error) {
+	for _, arg := range strings.Fields(s) {
+		switch {
+		case arg == "--reverse" || arg == "-r":
+			reverse = true
+		case by == "" && !strings.HasPrefix(arg, "-"):
+			by = arg
+		default:
+			return "", false, fmt.Errorf("invalid argument:%s: require [%s] [--reverse]", arg, strings.Join(gomem.KeySorts, "|"))
+		}
+	}
+	return by, reverse, nil
+}
+func la() (interface{}, error) {
+	return laOrder("")
+}
+func laOrder(s string) (interface{}, error) {
+	by, reverse, err := parseOrder(s)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	keys, err := igs.Keys(by, reverse)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	return entryList(newEntries(keys)), nil
+}
+func ls() (interface{}, error) {
+	return lsOrder("")
+}
+func lsOrder(s string) (interface{}, error) {
+	by, reverse, err := parseOrder(s)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	keys, err := igs.Keys(by, reverse)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	return keyList(newEntries(keys)), nil
+}
+func state() (interface{}, error) {
+	return stateOrder("")
+}
+func stateOrder(s string) (interface{}, error) {
+	by, reverse, err := parseOrder(s)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	keys, err := igs.Keys(by, reverse)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	v := stateView{
+		Dir:           igs.GetDir(),
+		ReadOnly:      igs.IsReadOnly(),
+		Subcategories: []string{},
+		Entries:       newEntries(keys),
+		Broken:        newBrokenViews(),
+	}
 	infos, err := ioutil.ReadDir(igs.GetDir())
 	if err == nil {
 		for _, info := range infos {
-			if info.IsDir() {
-				str += color.HiGreenString("sub categories:%s\n", info.Name())
+			if info.IsDir() && !strings.HasPrefix(info.Name(), ".") {
+				v.Subcategories = append(v.Subcategories, info.Name())
 			}
 		}
 	}
-	for key, v := range igs.Gmap {
-		str += color.GreenString("%s:", key)
-		str += color.MagentaString("[ %s ]:", v.J.Title)
-		str += fmt.Sprint("read only ")
-		if v.Override {
-			str += color.HiCyanString("%v\n", !v.Override)
-		} else {
-			str += color.RedString("%v\n", !v.Override)
+	return v, nil
+}
+func show(s string) (interface{}, error) {
+	key := s
+	path2json(&key)
+	if b, broken := igs.Broken[key]; broken {
+		return brokenView{Key: key, Error: b.Error()}, nil
+	}
+	key, err := lookupKey(s, nil)
+	if err != nil {
+		return nil, err
+	}
+	return contentView(newEntry(key, time.Now())), nil
+}
+func info(s string) (interface{}, error) {
+	s, err := lookupKey(s, nil)
+	if err != nil {
+		return nil, err
+	}
+	return infoView(newEntry(s, time.Now())), nil
+}
+func sortedMetaKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// search "[--regexp|--all|--rank] query...", query is joined by space
+func search(a *gomem.Args) (interface{}, error) {
+	mode := gomem.SearchSubstring
+	switch {
+	case a.Has("regexp"):
+		mode = gomem.SearchRegexp
+	case a.Has("all"):
+		mode = gomem.SearchAll
+	case a.Has("rank"):
+		mode = gomem.SearchRanked
+	}
+	s := strings.Join(a.Args, " ")
+	results, err := igs.Search(s, mode)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	if len(results) == 0 {
+		return nil, gomem.Failf("not found:%s", s)
+	}
+	list := make(matchList, 0, len(results))
+	for _, r := range results {
+		list = append(list, newMatch(r))
+	}
+	return list, nil
+}
+
+// todoCmd "todo [--sort by] [--filter field:value]..." for list,
+// "todo <name> [--due date] [--remind date] [--repeat rule] [--priority p]" for create
+func todoCmd(a *gomem.Args) (interface{}, error) {
+	if len(a.Args) == 0 {
+		for _, name := range []string{"due", "remind", "repeat", "priority"} {
+			if a.Has(name) {
+				return nil, gomem.Failf("--%s requires name of todo", name)
+			}
 		}
+		return listTodos(a.Value("sort"), a.Values("filter"))
 	}
-	return str, nil
+	if a.Has("sort") || a.Has("filter") {
+		return nil, gomem.Failf("--sort and --filter are for list, without name")
+	}
+	return createTodo(a)
 }
-func show(s string) (string, error) {
-	path2json(&s)
-	g, ok := igs.Gmap[s]
-	if !ok {
-		return "not found:" + color.GreenString(s), nil
+func listTodos(by string, filterArgs []string) (interface{}, error) {
+	var filters []gomem.TodoFilter
+	for _, s := range filterArgs {
+		f, err := gomem.ParseTodoFilter(s)
+		if err != nil {
+			return nil, gomem.Fail(err)
+		}
+		filters = append(filters, f)
 	}
-	return color.CyanString("%s\n", strings.Join(g.J.Content, "\n")), nil
+	keys, err := igs.SortTodos(by, filters...)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	return todoList(newEntries(keys)), nil
 }
-func todo() (string, error) {
-	var str string
-	var done string
-	for key, g := range igs.Gmap {
-		if strings.HasSuffix(g.J.Title, ":done") {
-			done += color.GreenString("%s:", key)
-			done += color.RedString("[ %s ]\n", g.J.Title)
-			done += color.CyanString("\t%s\n", strings.Join(g.J.Content, "\n\t"))
+func agenda() (interface{}, error) {
+	return agendaDays("7")
+}
+func agendaDays(s string) (interface{}, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return nil, gomem.Failf("invalid days:%s", s)
+	}
+	now := time.Now()
+	today := gomem.TruncateDay(now)
+	v := agendaView{Overdue: []entry{}, Days: make([]agendaDay, n)}
+	for i := range v.Days {
+		day := today.AddDate(0, 0, i)
+		v.Days[i] = agendaDay{Date: day.Format("2006-01-02"), Todos: []entry{}, day: day}
+	}
+	keys, err := igs.SortTodos("due")
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	for _, key := range keys {
+		g := igs.Gmap[key]
+		if g.J.Todo.Due == nil || g.J.Todo.Status.IsClosed() {
 			continue
 		}
-		if strings.HasPrefix(key, "todo"+string(filepath.Separator)) {
-			str += color.GreenString("%s:", key)
-			str += color.MagentaString("[ %s ]\n", g.J.Title)
-			str += color.CyanString("\t%s\n\n", strings.Join(g.J.Content, "\n\t"))
+		e := newEntry(key, now)
+		if e.Todo.dueState == gomem.DueOverdue {
+			v.Overdue = append(v.Overdue, e)
+			continue
+		}
+		due := gomem.TruncateDay(g.J.Todo.Due.In(now.Location()))
+		// round for daylight saving time
+		if i := int(due.Sub(today).Hours()+12) / 24; i < n {
+			v.Days[i].Todos = append(v.Days[i].Todos, e)
 		}
 	}
-	return done + str, nil
+	return v, nil
 }
 
 // contact to cache //
-func newGomem() (string, error) {
+func newGomem() (interface{}, error) {
 	// trim ..
 	fpath := filepath.Join(igs.GetDir(), read(prefname))
 	path2json(&fpath)
-	g, err := gomem.New(fpath, true)
-	if err != nil {
-		return err.Error(), nil
-	}
-	g.J.Title = read(pretitle)
-	g.J.Content = append(g.J.Content, read(precontent))
-	if err := igs.AddGomem(g); err != nil {
-		return err.Error(), nil
+	if err := addNewGomem(fpath); err != nil {
+		return nil, err
 	}
-	return "new gomem key:" + color.GreenString(fpath), nil
+	return message{Message: "new gomem key:" + keyString(fpath), Key: relKey(fpath)}, nil
 }
-func newGomemWithName(s string) (string, error) {
+func newGomemWithName(s string) (interface{}, error) {
 	s = filepath.Join(igs.GetDir(), path.Clean(s))
 	path2json(&s)
-	g, err := gomem.New(s, true)
+	if err := addNewGomem(s); err != nil {
+		return nil, err
+	}
+	return message{Message: "new gomem included", Key: relKey(s)}, nil
+}
+
+// addNewGomem add memo of fpath with title and content read from prompts
+// memo under todo/ is todo, content is first item
+func addNewGomem(fpath string) error {
+	newFunc := func(fpath string) (*gomem.Gomem, error) { return gomem.New(fpath, true) }
+	if strings.HasPrefix(relKey(fpath), "todo"+string(filepath.Separator)) {
+		newFunc = gomem.NewTodo
+	}
+	g, err := newFunc(fpath)
 	if err != nil {
-		return err.Error(), nil
+		return gomem.Fail(err)
 	}
 	g.J.Title = read(pretitle)
-	g.J.Content = append(g.J.Content, read(precontent))
+	if g.IsTodo() {
+		err = g.AddItem(read(precontent))
+	} else {
+		g.J.Content = append(g.J.Content, read(precontent))
+		g.SetDirty()
+	}
+	if err != nil {
+		return gomem.Fail(err)
+	}
 	if err := igs.AddGomem(g); err != nil {
-		return err.Error(), nil
+		return gomem.Fail(err)
 	}
-	return "new gomem included", nil
+	return nil
 }
-func include() (string, error) {
+func include() (interface{}, error) {
 	err := igs.IncludeJSON()
 	if err != nil {
-		return err.Error(), nil
+		return nil, gomem.Fail(err)
 	}
-	return "data cache reincluded: from " + color.HiGreenString(igs.GetDir()), nil
+	msg := "data cache reincluded: from " + color.HiGreenString(igs.GetDir())
+	if keys := igs.Dirty(); len(keys) != 0 {
+		msg += color.RedString("\nunsaved changes are kept:")
+		for _, key := range keys {
+			msg += keyString("\n\t%s", key)
+		}
+	}
+	return message{Message: msg}, nil
 }
-func cd() (string, error) {
+func cd() (interface{}, error) {
 	// TODO: cd: maybe don't needs use
 	//     : consider delete cd()
 	if !confirm("cd is dropped all data cache") {
-		return "", nil
+		return nil, errCanceled
 	}
 	pwd := igs.GetDir()
 	dir, err := filepath.Abs(filepath.Join(pwd, read("cd category:>")))
 	if err != nil {
-		return err.Error(), nil
+		return nil, gomem.Fail(err)
 	}
 	// reconsider: needs it?
 	if err := os.Chdir(dir); err != nil {
-		return err.Error(), nil
+		return nil, gomem.Fail(err)
+	}
+	// release lock before, dir may be same as pwd
+	// lock is released even if failed to write index
+	if err := igs.Close(); err != nil {
+		fmt.Fprintln(noticeWriter(), color.RedString("%v", err))
 	}
-	tmpgs, err := gomem.GomemsNew(dir)
+	tmpgs, err := openGomems(dir)
 	if err != nil {
 		// reconsider: needs it?
 		if err := os.Chdir(pwd); err != nil {
-			return err.Error(), nil
+			return nil, gomem.Fail(err)
 		}
-		return err.Error(), nil
+		if oldgs, err := openGomems(pwd); err == nil {
+			igs = oldgs
+		}
+		return nil, gomem.Fail(err)
 	}
 	igs = tmpgs
-	return "changed directory to:" + color.HiGreenString(igs.GetDir()), nil
+	return message{Message: "changed directory to:" + color.HiGreenString(igs.GetDir())}, nil
 }
-func modContent(s string) (string, error) {
-	path2json(&s)
-	g, ok := igs.Gmap[s]
-	if !ok {
-		return "not found:" + s, nil
+func modContent(s string) (interface{}, error) {
+	s, err := lookupKey(s, nil)
+	if err != nil {
+		return nil, err
 	}
-	msg := color.GreenString("%s:", s) +
-		color.MagentaString("[ %s ]", g.J.Title) +
-		color.CyanString("%s\n", g.J.Content)
+	g := igs.Gmap[s]
+	msg := keyString("%s:", s) +
+		titleString("[ %s ]", g.J.Title) +
+		contentString("%s\n", g.J.Lines())
 	c := read(msg + "mod " + precontent)
-	g.J.Content = append(g.J.Content, c)
-	return color.GreenString("content modified"), nil
+	if g.IsTodo() {
+		if err := g.AddItem(c); err != nil {
+			return nil, gomem.Fail(err)
+		}
+	} else {
+		g.J.Content = append(g.J.Content, c)
+		g.SetDirty()
+	}
+	return message{Message: keyString("content modified"), Key: s}, nil
+}
+
+// tag key [tag...], -tag for remove
+func tag(a *gomem.Args) (interface{}, error) {
+	args := a.Args
+	key, err := lookupKey(args[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	g := igs.Gmap[key]
+	for _, t := range args[1:] {
+		if strings.HasPrefix(t, "-") {
+			g.RemoveTags(strings.TrimPrefix(t, "-"))
+			continue
+		}
+		g.AddTags(strings.TrimPrefix(t, "#"))
+	}
+	return tagView(newEntry(key, time.Now())), nil
 }
-func toggleReadonly(s string) (string, error) {
+func toggleReadonly(s string) (interface{}, error) {
 	path2json(&s)
 	g, ok := igs.Gmap[s]
 	if !ok {
-		return "not found" + color.GreenString(s), nil
+		return nil, gomem.Failf("not found:%s", keyString(s))
 	}
 	g.Override = !g.Override
-	str := color.GreenString("key:%s", s)
+	str := keyString("key:%s", s)
 	str += color.HiRedString("readonly:%+v", g.Override)
-	return str, nil
+	return message{Message: str, Key: s}, nil
 }
-func removeCache(s string) (string, error) {
-	path2json(&s)
-	if _, ok := igs.Gmap[s]; !ok {
-		return "not found:" + color.GreenString(s), nil
+func removeCache(s string) (interface{}, error) {
+	s, err := lookupKey(s, nil)
+	if err != nil {
+		return nil, err
 	}
 	if confirm("remove cache:"+s) == false {
-		return "", nil
+		return nil, errCanceled
 	}
 	delete(igs.Gmap, s)
-	return color.RedString("removed cache:" + s), nil
+	return message{Message: color.RedString("removed cache:" + s), Key: s}, nil
 }
-func appendTodo(s string) (string, error) {
-	path2json(&s)
-	s = filepath.Join("todo", s)
-	g, ok := igs.Gmap[s]
-	if !ok {
-		return "not found:" + color.GreenString(s), nil
+
+// getTodo return todo of key todo/s
+// if not exists then lookup todo by fuzzy matching
+func getTodo(s string) (string, *gomem.Gomem, error) {
+	key := s
+	path2json(&key)
+	key = filepath.Join("todo", key)
+	if g, ok := igs.Gmap[key]; ok {
+		if !g.IsTodo() {
+			return key, nil, gomem.Failf("not todo:%s", keyString(key))
+		}
+		return key, g, nil
 	}
-	g.J.Content = append(g.J.Content, read("append "+precontent))
-	g.J.Title = strings.TrimSuffix(g.J.Title, ":done")
-	return "cache in:" +
-			color.GreenString("%s:", s) +
-			color.MagentaString("[ %s ]\n", g.J.Title) +
-			color.CyanString("%s", strings.Join(g.J.Content, "\n")),
-		nil
+	key, err := lookupKey(s, isTodo)
+	if err != nil {
+		return s, nil, err
+	}
+	return key, igs.Gmap[key], nil
 }
-func done(s string) (string, error) {
-	path2json(&s)
-	s = filepath.Join("todo", s)
-	g, ok := igs.Gmap[s]
-	if !ok {
-		return "not found " + s, nil
+func appendTodo(s string) (interface{}, error) {
+	s, g, err := getTodo(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.AddItem(read("append " + precontent)); err != nil {
+		return nil, gomem.Fail(err)
+	}
+	return todoView{entry: newEntry(s, time.Now()), header: "cache in:" + keyString("%s:", s)}, nil
+}
+func done(s string) (interface{}, error) {
+	return setStatus(s, gomem.StatusDone)
+}
+func start(s string) (interface{}, error) {
+	return setStatus(s, gomem.StatusInProgress)
+}
+func cancel(s string) (interface{}, error) {
+	return setStatus(s, gomem.StatusCancelled)
+}
+func reopen(s string) (interface{}, error) {
+	return setStatus(s, gomem.StatusOpen)
+}
+func setStatus(s string, st gomem.Status) (interface{}, error) {
+	s, g, err := getTodo(s)
+	if err != nil {
+		return nil, err
+	}
+	if g.J.Todo.Status == st {
+		return message{Message: "already " + string(st) + ":" + keyString(s), Key: s}, nil
+	}
+	if err := g.SetStatus(st); err != nil {
+		return nil, gomem.Fail(err)
+	}
+	header := keyString("%s:", s)
+	if st == gomem.StatusDone && g.J.Todo.Recur != nil {
+		header += "recurring, next "
+	}
+	return todoView{entry: newEntry(s, time.Now()), header: header}, nil
+}
+
+func setDue(a *gomem.Args) (interface{}, error) {
+	return setDate(a.Args, (*gomem.Gomem).SetDue)
+}
+func setRemind(a *gomem.Args) (interface{}, error) {
+	return setDate(a.Args, (*gomem.Gomem).SetRemind)
+}
+
+// setDate "name date", date "none" for clear
+func setDate(args []string, set func(*gomem.Gomem, *time.Time) error) (interface{}, error) {
+	key, g, err := getTodo(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var t *time.Time
+	if args[1] != "none" {
+		d, err := gomem.ParseDate(args[1], time.Now())
+		if err != nil {
+			return nil, gomem.Fail(err)
+		}
+		t = &d
+	}
+	if err := set(g, t); err != nil {
+		return nil, gomem.Fail(err)
+	}
+	return todoView{entry: newEntry(key, time.Now()), header: keyString("%s:", key)}, nil
+}
+
+// priority "name p", p "none" for clear
+func priority(a *gomem.Args) (interface{}, error) {
+	args := a.Args
+	key, g, err := getTodo(args[0])
+	if err != nil {
+		return nil, err
+	}
+	p, err := gomem.ParsePriority(args[1])
+	if err != nil {
+		return nil, gomem.Fail(err)
 	}
-	if strings.HasSuffix(g.J.Title, ":done") {
-		return "already done:" + color.GreenString(s), nil
+	if err := g.SetPriority(p); err != nil {
+		return nil, gomem.Fail(err)
 	}
-	g.J.Title += ":done"
-	return color.GreenString("%s:", s) +
-			color.RedString("[ %s ]\n", g.J.Title) +
-			color.CyanString("%s\n", strings.Join(g.J.Content, "\n")),
-		nil
+	return todoView{entry: newEntry(key, time.Now()), header: keyString("%s:", key)}, nil
 }
-func trim(s string) (string, error) {
+
+// repeat "name rule", rule "none" for clear
+func repeat(a *gomem.Args) (interface{}, error) {
+	args := a.Args
+	key, g, err := getTodo(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var r *gomem.Recurrence
+	if args[1] != "none" {
+		base := time.Now()
+		if g.J.Todo.Due != nil {
+			base = *g.J.Todo.Due
+		}
+		var err error
+		if r, err = gomem.ParseRecurrence(args[1], base); err != nil {
+			return nil, gomem.Fail(err)
+		}
+	}
+	if err := g.SetRecur(r); err != nil {
+		return nil, gomem.Fail(err)
+	}
+	return todoView{entry: newEntry(key, time.Now()), header: keyString("%s:", key)}, nil
+}
+
+// selectItem read line number of item, return index from 0
+func selectItem(g *gomem.Gomem) (int, error) {
+	var msg string
+	for i, item := range g.J.Todo.Items {
+		msg += fmt.Sprintf("%d: %s\n", i+1, contentString(item.Text))
+	}
+	i, err := strconv.Atoi(read(msg + "line :> "))
+	if err != nil {
+		return 0, err
+	}
+	if i <= 0 || i > len(g.J.Todo.Items) {
+		return 0, fmt.Errorf("invalid line number:%d", i)
+	}
+	return i - 1, nil
+}
+func trim(s string) (interface{}, error) {
+	key, g, err := getTodo(s)
+	if err != nil {
+		return nil, err
+	}
+	i, err := selectItem(g)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	if err := g.RemoveItem(i); err != nil {
+		return nil, gomem.Fail(err)
+	}
+	return itemsView(newEntry(key, time.Now())), nil
+}
+func check(s string) (interface{}, error) {
+	key, g, err := getTodo(s)
+	if err != nil {
+		return nil, err
+	}
+	i, err := selectItem(g)
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	if err := g.ToggleItem(i); err != nil {
+		return nil, gomem.Fail(err)
+	}
+	return itemsView(newEntry(key, time.Now())), nil
+}
+
+func repair(s string) (interface{}, error) {
 	path2json(&s)
-	s = filepath.Join("todo", s)
-	g, ok := igs.Gmap[s]
+	b, ok := igs.Broken[s]
 	if !ok {
-		return "not found" + color.GreenString(s), nil
+		return nil, gomem.Failf("not broken:%s", keyString(s))
 	}
-	var msg string
-	for i, s := range g.J.Content {
-		msg += fmt.Sprintf("%d: %s\n", i+1, color.CyanString(s))
+	f, err := ioutil.TempFile("", "gomem-repair-*.json")
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b.Raw)
+	if cerr := f.Close(); err == nil {
+		err = cerr
 	}
-	trimIndex, err := strconv.Atoi(read(msg + "line :> "))
 	if err != nil {
-		return err.Error(), nil
+		return nil, gomem.Fail(err)
 	}
-	if trimIndex <= 0 || trimIndex > len(g.J.Content) {
-		return "invalid line number:" + strconv.Itoa(trimIndex), nil
+	fmt.Fprintln(noticeWriter(), color.RedString("broken:%v", b))
+	if err := editFile(f.Name()); err != nil {
+		return nil, gomem.Fail(err)
 	}
-	trimIndex--
-	g.J.Content = append(g.J.Content[:trimIndex], g.J.Content[trimIndex+1:]...)
-	return color.CyanString("%s", strings.Join(g.J.Content, "\n")), nil
+	raw, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	if _, err := igs.Repair(s, raw); err != nil {
+		return nil, gomem.Failf("%s", color.RedString("still broken:%v\n", err)+"retry repair "+keyString(s))
+	}
+	return message{Message: "repaired in cache:" + keyString(s), Key: s}, nil
+}
+
+// editFile open fpath by $EDITOR, default vi
+func editFile(fpath string) error {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], fpath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
 }
 
 // physical //
-func makeSubcategory(s string) (string, error) {
+
+// checkWritable return failure in read only session, for changes of files without write
+func checkWritable() error {
+	if igs.IsReadOnly() {
+		return gomem.Fail(gomem.ErrReadOnly)
+	}
+	return nil
+}
+func makeSubcategory(s string) (interface{}, error) {
+	if err := checkWritable(); err != nil {
+		return nil, err
+	}
 	subname := filepath.Join(igs.GetDir(), filepath.Base(s))
 	err := os.Mkdir(subname, 0777)
 	if err != nil {
-		return err.Error(), nil
+		return nil, gomem.Fail(err)
 	}
-	return "maked subcategory:" + color.HiGreenString(subname), nil
+	return message{Message: "maked subcategory:" + color.HiGreenString(subname)}, nil
 }
-func write() (string, error) {
-	b := confirm("write all cache in " + color.HiGreenString(igs.GetDir()))
-	var result string
+func write() (interface{}, error) {
+	keys := igs.Dirty()
+	if len(keys) == 0 {
+		return message{Message: "no changes to write"}, nil
+	}
+	b := confirm("write " + strconv.Itoa(len(keys)) + " changed cache in " + color.HiGreenString(igs.GetDir()))
 	if b {
-		for key, x := range igs.Gmap {
-			if err := x.WriteFile(); err != nil {
-				result += color.RedString("err:%s:%s\n", key, err.Error())
+		report, err := writeKeys(keys)
+		if err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+	return nil, gomem.Failf("stop write")
+}
+
+// writeKeys write igs.Gmap[keys] and index, return report
+// failed keys are reported by status, error is for read only session
+// failure of index is reported as key of gomem.IndexDir
+func writeKeys(keys []string) (writeReport, error) {
+	if igs.IsReadOnly() {
+		return nil, gomem.Failf("%s", color.RedString("read only session: cannot write"))
+	}
+	report := make(writeReport, 0, len(keys))
+	for _, key := range keys {
+		x := igs.Gmap[key]
+		err := x.WriteFile()
+		if err == gomem.ErrConflict {
+			var status string
+			status, err = resolveConflict(key, x)
+			if err == nil {
+				report = append(report, written{Key: key, Status: status})
+				continue
 			}
 		}
-		return result, nil
+		if err != nil {
+			report = append(report, written{Key: key, Status: "error", Error: err.Error()})
+			continue
+		}
+		report = append(report, written{Key: key, Status: "written"})
+	}
+	if err := igs.SaveIndex(); err != nil {
+		report = append(report, written{Key: gomem.IndexDir, Status: "error", Error: err.Error()})
 	}
-	return "stop write", nil
+	return report, nil
 }
-func remove(s string) (string, error) {
-	path2json(&s)
+
+// resolveConflict for gomem.ErrConflict
+// mine: overwrite file on disk, theirs: reload from disk, diff: show diff and confirm again
+// return status of key, if skipped then g is kept dirty
+func resolveConflict(key string, g *gomem.Gomem) (string, error) {
+	msg := color.RedString("conflict:%s: modified on disk since read\n", key)
+	msg += "[mine:theirs:diff:skip]?>"
+	for i := 0; i < 4; i++ {
+		line, ok := readLine(msg)
+		if !ok {
+			break
+		}
+		msg = "[mine:theirs:diff:skip]?>"
+		switch line {
+		case "mine", "m":
+			if err := g.Rebase(); err != nil {
+				return "", err
+			}
+			if err := g.WriteFile(); err != nil {
+				return "", err
+			}
+			return "written", nil
+		case "theirs", "t":
+			if err := g.ReadFile(); err != nil {
+				return "", err
+			}
+			return "reloaded", nil
+		case "diff", "d":
+			theirs, err := g.ReadDiskJSON()
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprint(noticeWriter(), diffJSON(theirs, g.J))
+		case "skip", "s":
+			return "skipped", nil
+		}
+	}
+	return "skipped", nil
+}
+
+// diffJSON return line diff, "-" is theirs and "+" is mine
+func diffJSON(theirs, mine gomem.JSON) string {
+	a := append([]string{theirs.Title}, theirs.Lines()...)
+	b := append([]string{mine.Title}, mine.Lines()...)
+	// longest common subsequence
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var str string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			str += fmt.Sprintf("  %s\n", a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			str += keyString("+ %s\n", b[j])
+			j++
+		default:
+			str += color.RedString("- %s\n", a[i])
+			i++
+		}
+	}
+	return str
+}
+
+// migrate //
+func migrate() (interface{}, error) {
+	keys := igs.Outdated()
+	if len(keys) == 0 {
+		return message{Message: "all files are version " + strconv.Itoa(gomem.SchemaVersion)}, nil
+	}
+	fmt.Fprint(noticeWriter(), newMigrationView(keys, false))
+	if !confirm("rewrite " + strconv.Itoa(len(keys)) + " files to version " + strconv.Itoa(gomem.SchemaVersion)) {
+		return nil, gomem.Failf("stop migrate")
+	}
+	report, err := writeKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+func migrateDryRun(s string) (interface{}, error) {
+	if s != "-n" && s != "dry-run" {
+		return nil, gomem.Failf("invalid argument:%s: migrate [-n|dry-run]", s)
+	}
+	keys := igs.Outdated()
+	if len(keys) == 0 {
+		return message{Message: "all files are version " + strconv.Itoa(gomem.SchemaVersion)}, nil
+	}
+	return newMigrationView(keys, true), nil
+}
+func remove(s string) (interface{}, error) {
+	s, err := lookupKey(s, nil)
+	if err != nil {
+		return nil, err
+	}
 	fullpath, err := igs.GetAbs(s)
 	if err != nil {
-		return err.Error(), nil
+		return nil, gomem.Fail(err)
+	}
+	if err := checkWritable(); err != nil {
+		return nil, err
 	}
 	if confirm("remove:"+fullpath) == false {
-		return "", nil
+		return nil, errCanceled
 	}
-	err = os.Remove(fullpath)
+	err = igs.Remove(s)
 	if err != nil {
-		return err.Error(), nil
+		return nil, gomem.Fail(err)
 	}
-	delete(igs.Gmap, s)
-	return color.RedString(fullpath + " is removed"), nil
+	return message{Message: color.RedString(fullpath + " is removed"), Key: s}, nil
 }
-func removeSubcategory(s string) (string, error) {
+func removeSubcategory(s string) (interface{}, error) {
+	if err := checkWritable(); err != nil {
+		return nil, err
+	}
 	subname := filepath.Join(igs.GetDir(), filepath.Base(s))
 	info, err := os.Lstat(subname)
 	if err != nil {
-		return err.Error(), nil
+		return nil, gomem.Fail(err)
 	}
 	if !info.IsDir() {
-		return "invalid category:" + color.HiGreenString(s), nil
+		return nil, gomem.Failf("invalid category:%s", color.HiGreenString(s))
 	}
 	if !confirm("remove all files in " + subname) {
-		return "", nil
+		return nil, errCanceled
 	}
 	err = os.RemoveAll(subname)
 	if err != nil {
-		return err.Error(), nil
+		return nil, gomem.Fail(err)
 	}
-	return color.RedString("removed subcategory:" + subname), nil
+	return message{Message: color.RedString("removed subcategory:" + subname)}, nil
 }
-func createTodo(s string) (string, error) {
+
+// createTodo "name [--due date] [--remind date] [--repeat rule] [--priority p]"
+func createTodo(a *gomem.Args) (interface{}, error) {
+	now := time.Now()
+	var due, remind *time.Time
+	for _, v := range []struct {
+		name string
+		t    **time.Time
+	}{{name: "due", t: &due}, {name: "remind", t: &remind}} {
+		if !a.Has(v.name) {
+			continue
+		}
+		t, err := gomem.ParseDate(a.Value(v.name), now)
+		if err != nil {
+			return nil, gomem.Fail(err)
+		}
+		*v.t = &t
+	}
+	var p gomem.Priority
+	if a.Has("priority") {
+		var err error
+		if p, err = gomem.ParsePriority(a.Value("priority")); err != nil {
+			return nil, gomem.Fail(err)
+		}
+	}
+	var recur *gomem.Recurrence
+	if a.Has("repeat") {
+		base := now
+		if due != nil {
+			base = *due
+		}
+		var err error
+		if recur, err = gomem.ParseRecurrence(a.Value("repeat"), base); err != nil {
+			return nil, gomem.Fail(err)
+		}
+	}
+	s := a.Args[0]
 	path2json(&s)
 	s = filepath.Join("todo", s)
-	g, err := gomem.New(filepath.Join(igs.GetDir(), s), true)
+	g, err := gomem.NewTodo(filepath.Join(igs.GetDir(), s))
+	if err != nil {
+		return nil, gomem.Fail(err)
+	}
+	g.J.Title = strings.TrimSuffix(filepath.Base(s), ".json")
+	g.J.Todo.Due = due
+	g.J.Todo.Remind = remind
+	g.J.Todo.Priority = p
+	if recur != nil {
+		if err := g.SetRecur(recur); err != nil {
+			return nil, gomem.Fail(err)
+		}
+	}
+	if err := g.AddItem(read(precontent)); err != nil {
+		return nil, gomem.Fail(err)
+	}
+	if err := igs.AddGomem(g); err != nil {
+		return nil, gomem.Fail(err)
+	}
+	return todoView{entry: newEntry(s, time.Now()), header: "cache in:" + keyString("%s\n", s)}, nil
+}
+
+// exit //
+// quit exit if no unsaved changes, else write or discard by prompt
+// canceled or no answer is failure
+func quit() (interface{}, error) {
+	keys := igs.Dirty()
+	if len(keys) == 0 {
+		return nil, gomem.ErrValidExit
+	}
+	msg := color.RedString("unsaved changes:\n")
+	for _, key := range keys {
+		msg += keyString("\t%s\n", key)
+	}
+	msg += "[write:discard:cancel]?>"
+	for i := 0; i < 2; i++ {
+		line, ok := readLine(msg)
+		if !ok {
+			break
+		}
+		msg = "[write:discard:cancel]?>"
+		switch line {
+		case "write", "w":
+			return writeQuit()
+		case "discard", "d":
+			return quitDiscard()
+		case "cancel", "c":
+			return nil, gomem.Failf("cancel exit")
+		}
+	}
+	return nil, gomem.Failf("cancel exit")
+}
+func writeQuit() (interface{}, error) {
+	report, err := writeKeys(igs.Dirty())
+	if err != nil {
+		return nil, err
+	}
+	if !report.ok() {
+		return report, gomem.Failf("cancel exit")
+	}
+	return report, gomem.ErrValidExit
+}
+func quitDiscard() (interface{}, error) {
+	return nil, gomem.ErrValidExit
+}
+
+// openGomems open dir with lock
+// if locked by another session then fallback to read only
+// files failed to load are notified without -strict
+func openGomems(dir string) (*gomem.Gomems, error) {
+	gs, err := gomem.GomemsNew(dir)
+	if gomem.IsLocked(err) {
+		fmt.Fprintln(noticeWriter(), color.RedString("%v: open as read only", err))
+		gs, err = gomem.GomemsNewReadOnly(dir)
+	}
+	if _, ok := err.(*gomem.LoadError); ok && gs != nil {
+		fmt.Fprintln(noticeWriter(), color.RedString("%v", err))
+		return gs, nil
+	}
+	return gs, err
+}
+
+// openHistory return history by opt, if failed then history in memory
+func openHistory(w io.Writer) *gomem.History {
+	path := opt.history
+	if path == "none" {
+		path = ""
+	}
+	h, err := gomem.LoadHistory(path, opt.historySize)
 	if err != nil {
-		return err.Error(), nil
+		fmt.Fprintln(w, "history:", err)
+		h, _ = gomem.LoadHistory("", opt.historySize)
 	}
-	t := time.Now()
-	ts := fmt.Sprintf("%d %s %d %d:%d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute())
-	g.J.Title = fmt.Sprintf("<%s>:%s", ts, s)
-	g.J.Content = append(g.J.Content, read(precontent))
-	igs.Gmap[s] = g
-	return "cache in:" + color.GreenString("%s\n", s) +
-			color.MagentaString("[ %s ]\n", g.J.Title) +
-			color.CyanString("%s", strings.Join(g.J.Content, "\n")),
-		nil
+	return h
+}
+
+// newSubCommands return all commands
+func newSubCommands(r io.Reader, w io.Writer) *gomem.SubCommands {
+	sub := gomem.SubNew(r, w)
+	sub.Handle("exit", quit, "call exit, confirm if unsaved changes")
+	sub.Handle(":q", quit, "exit alias")
+	sub.Handle(":wq", writeQuit, "write changed data and exit")
+	sub.Handle(":q!", quitDiscard, "exit without write")
+	sub.Addf("help", sub.Help, "show subcommands")
+	sub.Addf("history", sub.ListHistory, "show command history, !n for run nth command, !! for last")
+	sub.Handle("la", la, "show gs.Gmap")
+	sub.Handle("ls", ls, "ls gs.Gmap keys")
+	sub.Handle("state", state, "show state of gs")
+	sub.Handle("new", newGomem, "new gomem")
+	sub.Handle("write", write, "write changed data to gs.dir")
+	sub.Handle("cd", cd, "change working directory, and exchange of data cache")
+	sub.Handle("include", include, "reinclude from gs.dir")
+	sub.Handle("agenda", agenda, "show todo due in next 7 days")
+	sub.Handle("migrate", migrate, "rewrite files of old schema to current version")
+
+	sub.Addfa("history", sub.ListHistoryN, "history <n> show last n commands")
+	sub.HandleArg("la", laOrder, "la [key|title|modified|created] [--reverse]")
+	sub.HandleArg("ls", lsOrder, "ls [key|title|modified|created] [--reverse]")
+	sub.HandleArg("state", stateOrder, "state [key|title|modified|created] [--reverse]")
+	sub.HandleArg("show", show, "show title and content")
+	sub.HandleArg("info", info, "show id, timestamps, tags and meta")
+	sub.HandleArg("mkdir", makeSubcategory, "mkdir make subcategory in gs.dir")
+	sub.HandleArg("rm", remove, "remove physical file")
+	sub.HandleArg("rmsub", removeSubcategory, "remove subcategory directory")
+	sub.HandleArg("rmcache", removeCache, "remove cache data")
+	sub.HandleArg("new", newGomemWithName, "")
+	sub.HandleArg("mod", modContent, "modify content")
+	sub.HandleArg("agenda", agendaDays, "agenda <days>")
+	sub.HandleArg("done", done, "for [todo/*] set status done")
+	sub.HandleArg("start", start, "for [todo/*] set status in-progress")
+	sub.HandleArg("cancel", cancel, "for [todo/*] set status cancelled")
+	sub.HandleArg("reopen", reopen, "for [todo/*] set status open")
+	sub.HandleArg("append", appendTodo, "append item to todo")
+	sub.HandleArg("trim", trim, "trim item in todo")
+	sub.HandleArg("check", check, "toggle check of item in todo")
+	sub.HandleArg("readonly!", toggleReadonly, "toggle readonly falg")
+	sub.HandleArg("migrate", migrateDryRun, "migrate -n: show files to rewrite without write")
+	sub.HandleArg("repair", repair, "edit raw text of broken json by $EDITOR")
+
+	sub.Handle("format", func() (interface{}, error) {
+		return message{Message: "format:" + sub.Format()}, nil
+	}, "show output format")
+	sub.HandleArg("format", func(s string) (interface{}, error) {
+		if err := setFormat(sub, s); err != nil {
+			return nil, gomem.Fail(err)
+		}
+		return message{Message: "format:" + sub.Format()}, nil
+	}, "format <"+strings.Join(gomem.Formats(), "|")+"> change output format")
+	sub.AddCommand(&gomem.Command{
+		Name: "run", Args: "<script> [arg...]", MinArgs: 1, MaxArgs: -1,
+		Help: "run commands in script file, see gomem -h",
+		Run: func(a *gomem.Args) (interface{}, error) {
+			return nil, runScript(sub, a.Args[0], a.Args[1:])
+		},
+	})
+
+	if err := setFormat(sub, opt.format); err != nil {
+		fmt.Fprintln(w, err)
+	}
+
+	sub.AddCommand(&gomem.Command{
+		Name: "search", Args: "<query>...", MinArgs: 1, MaxArgs: -1,
+		Flags: []gomem.Flag{
+			{Name: "regexp", Short: "e", Usage: "query is regular expression"},
+			{Name: "all", Short: "a", Usage: "match all words"},
+			{Name: "rank", Short: "r", Usage: "match any word, sort by relevance"},
+		},
+		Help: "search title and content, default is case-insensitive substring",
+		Run:  search,
+	})
+	sub.AddCommand(&gomem.Command{
+		Name: "tag", Args: "<key> [tag...]", MinArgs: 1, MaxArgs: -1,
+		Help: "add tags, -tag for remove",
+		Run:  tag,
+	})
+	sub.AddCommand(&gomem.Command{
+		Name: "todo", Args: "[name]", MaxArgs: 1,
+		Flags: []gomem.Flag{
+			{Name: "sort", Arg: "priority|due|created", Usage: "sort of list"},
+			{Name: "filter", Arg: "field:value", Usage: "filter of list by status, priority, tag or due, repeatable"},
+			{Name: "due", Arg: "date", Usage: "due date of new todo"},
+			{Name: "remind", Arg: "date", Usage: "reminder of new todo"},
+			{Name: "repeat", Arg: "rule", Usage: "recurrence of new todo: daily, weekly[:weekday], monthly[:day] or Nd"},
+			{Name: "priority", Arg: "A-E", Usage: "priority of new todo"},
+		},
+		Help: "list todo [todo/*] without name, create todo with name",
+		Run:  todoCmd,
+	})
+	sub.AddCommand(&gomem.Command{
+		Name: "due", Args: "<name> <date|none>", MinArgs: 2, MaxArgs: 2,
+		Help: "set due date, date: 2006-01-02, tomorrow, friday, +3d",
+		Run:  setDue,
+	})
+	sub.AddCommand(&gomem.Command{
+		Name: "remind", Args: "<name> <date|none>", MinArgs: 2, MaxArgs: 2,
+		Help: "set reminder",
+		Run:  setRemind,
+	})
+	sub.AddCommand(&gomem.Command{
+		Name: "priority", Args: "<name> <A-E|1-5|none>", MinArgs: 2, MaxArgs: 2,
+		Help: "set priority",
+		Run:  priority,
+	})
+	sub.AddCommand(&gomem.Command{
+		Name: "repeat", Args: "<name> <daily|weekly[:weekday]|monthly[:day]|Nd|none>", MinArgs: 2, MaxArgs: 2,
+		Help: "set recurrence",
+		Run:  repeat,
+	})
+
+	return sub
+}
+
+// runScript run script file by sub, relative path is from startDir
+// here-document of command is input of read and confirm, or empty
+func runScript(sub *gomem.SubCommands, path string, args []string) error {
+	if !filepath.IsAbs(path) && startDir != "" {
+		path = filepath.Join(startDir, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return gomem.Fail(err)
+	}
+	defer f.Close()
+	r, in := interReader, noPrompt
+	defer func() { interReader, noPrompt = r, in }()
+	noPrompt = true
+	sub.ScriptInput = func(in io.Reader) { interReader = in }
+	if err := sub.RunScript(filepath.Base(path), f, args, interErrWriter); err != nil {
+		return gomem.Fail(err)
+	}
+	return nil
 }
 
 // interactive make interactive session
@@ -373,32 +1327,7 @@ func interactive(r io.Reader, w io.Writer, prefix string, gs *gomem.Gomems, auto
 	interReader = r
 	interWriter = w
 
-	sub := gomem.SubNew(r, w)
-	sub.Addf("exit", sub.Exit, "call exit")
-	sub.Addf(":q", sub.Exit, "exit alias")
-	sub.Addf("help", sub.Help, "show subcommands")
-	sub.Addf("la", la, "show gs.Gmap")
-	sub.Addf("ls", ls, "ls gs.Gmap keys")
-	sub.Addf("state", state, "show state of gs")
-	sub.Addf("new", newGomem, "new gomem")
-	sub.Addf("write", write, "write all data to gs.dir")
-	sub.Addf("cd", cd, "change working directory, and exchange of data cache")
-	sub.Addf("todo", todo, "subcategory [todo/*]")
-	sub.Addf("include", include, "reinclude from gs.dir")
-
-	sub.Addfa("show", show, "show title and content")
-	sub.Addfa("mkdir", makeSubcategory, "mkdir make subcategory in gs.dir")
-	sub.Addfa("rm", remove, "remove physical file")
-	sub.Addfa("rmsub", removeSubcategory, "remove subcategory directory")
-	sub.Addfa("rmcache", removeCache, "remove cache data")
-	sub.Addfa("new", newGomemWithName, "")
-	sub.Addfa("mod", modContent, "modify content")
-	sub.Addfa("todo", createTodo, "")
-	sub.Addfa("done", done, "for [todo/*] check done flag")
-	sub.Addfa("append", appendTodo, "append todo")
-	sub.Addfa("trim", trim, "trim in todo")
-	sub.Addfa("readonly!", toggleReadonly, "toggle readonly falg")
-
+	sub := newSubCommands(r, w)
 	if autoRuns != nil {
 		sub.InterCh = make(chan string, len(autoRuns))
 		for _, s := range autoRuns {
@@ -411,9 +1340,94 @@ func interactive(r io.Reader, w io.Writer, prefix string, gs *gomem.Gomems, auto
 			sub.CallBackBuf <- s
 		}
 	}
+	sub.ArgComplete = completeArg
+	sub.History = openHistory(w)
+	ihistory = sub.History
+	if f, ok := r.(*os.File); ok {
+		// fallback to scanner if not terminal
+		if le, err := lineedit.NewLineEditor(f, w, opt.keymap); err == nil {
+			le.Complete = sub.Complete
+			le.History = sub.History
+			sub.LineReader = le
+		}
+	}
+	interReadLine = sub.ReadLine
 	sub.Prefix = prefix
 	if err := sub.Repl(); err != nil {
 		return err
 	}
 	return nil
 }
+
+// exit status of oneShot
+const (
+	exitOK      = 0
+	exitFailure = 1 // command failed or could not write
+	exitUsage   = 2 // unknown command or invalid arguments
+)
+
+// exitStatus return exit status of error of SubCommands.Run
+// error of script is by error of command
+func exitStatus(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return exitOK
+	case *gomem.UsageError:
+		return exitUsage
+	case *gomem.CommandError:
+		if se, ok := e.Err.(*gomem.ScriptError); ok && se.Cmd != "" {
+			return exitStatus(se.Err)
+		}
+		return exitFailure
+	}
+	switch err {
+	case gomem.ErrValidExit:
+		return exitOK
+	case gomem.ErrUnknownCommand:
+		return exitUsage
+	}
+	return exitFailure
+}
+
+// oneShot run args[0] with args[1:] without prompt, return exit status
+// input of prompts is from r, declined confirm or no answer is failure
+// result is written to w, error and notices are written to ew
+// changed cache is written before return
+func oneShot(r io.Reader, w, ew io.Writer, gs *gomem.Gomems, args []string) int {
+	igs = gs
+	interReader = r
+	interWriter = w
+	interErrWriter = ew
+	noPrompt = true
+
+	sub := newSubCommands(r, w)
+	result, err := sub.Run(args[0], args[1:])
+	if status := exitStatus(err); status != exitOK {
+		if err == gomem.ErrUnknownCommand {
+			err = fmt.Errorf("%v: %s", err, args[0])
+		}
+		fmt.Fprintln(ew, errorOutput(err, status))
+		return status
+	}
+	out, err := sub.Render(result)
+	if err != nil {
+		fmt.Fprintln(ew, errorOutput(err, exitFailure))
+		return exitFailure
+	}
+	if out != "" {
+		fmt.Fprintln(w, strings.TrimSuffix(out, "\n"))
+	}
+	if keys := igs.Dirty(); len(keys) != 0 {
+		report, err := writeKeys(keys)
+		if err != nil {
+			fmt.Fprintln(ew, errorOutput(err, exitFailure))
+			return exitFailure
+		}
+		if !report.ok() {
+			out, _ := sub.Render(report)
+			fmt.Fprintln(ew, strings.TrimSuffix(out, "\n"))
+			return exitFailure
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kamisari/gomem"
+)
+
+func init() {
+	textNoColor = true
+}
+
+// runOneShot run args by oneShot in new session of dir, input is stdin
+// return stdout, stderr and exit status
+func runOneShot(t *testing.T, dir, format, input string, args ...string) (string, string, int) {
+	t.Helper()
+	gs, err := gomem.GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt.format = format
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	status := oneShot(strings.NewReader(input), out, errOut, gs, args)
+	if err := igs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), status
+}
+
+func TestOneShot_Confirm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomemcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, key := range []string{"foo", "food"} {
+		if _, stderr, status := runOneShot(t, dir, "text", "title\ncontent\n", "new", key); status != exitOK {
+			t.Fatalf("new %s: status %d: %s", key, status, stderr)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input  string
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		// no answer and declined
+		{args: []string{"rm", "foo"}, status: exitFailure, stderr: "canceled\n"},
+		{input: "no\n", args: []string{"rm", "foo"}, status: exitFailure, stderr: "canceled\n"},
+		{args: []string{"rmcache", "foo"}, status: exitFailure, stderr: "canceled\n"},
+		{args: []string{"rmsub", "sub"}, status: exitFailure, stderr: "canceled\n"},
+		{args: []string{"show", "fo"}, status: exitFailure, stderr: "canceled\n"},
+		{input: "9\n", args: []string{"show", "fo"}, status: exitFailure, stderr: "canceled\n"},
+		// answered, prompts are not printed
+		{input: "2\n", args: []string{"info", "fo"}, status: exitOK, stdout: "food.json"},
+		{input: "yes\n", args: []string{"rm", "foo"}, status: exitOK, stdout: "foo.json is removed"},
+	}
+	for _, v := range tests {
+		stdout, stderr, status := runOneShot(t, dir, "text", v.input, v.args...)
+		if status != v.status || stderr != v.stderr || !strings.Contains(stdout, v.stdout) {
+			t.Errorf("%q %q: want %d %q %q but got %d %q %q",
+				v.args, v.input, v.status, v.stdout, v.stderr, status, stdout, stderr)
+		}
+		if strings.Contains(stdout, "?>") || strings.Contains(stdout, ":>") {
+			t.Errorf("%q: prompt is printed: %q", v.args, stdout)
+		}
+	}
+	for _, v := range []struct {
+		name   string
+		exists bool
+	}{{"food.json", true}, {"foo.json", false}, {"sub", true}} {
+		if _, err := os.Stat(filepath.Join(dir, v.name)); os.IsNotExist(err) == v.exists {
+			t.Errorf("%s: want exists %v but got %v", v.name, v.exists, err)
+		}
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: nil, want: exitOK},
+		{err: gomem.ErrValidExit, want: exitOK},
+		{err: gomem.Failf("failed"), want: exitFailure},
+		{err: errors.New("other"), want: exitFailure},
+		{err: &gomem.UsageError{Cmd: &gomem.Command{Name: "x"}}, want: exitUsage},
+		{err: gomem.ErrUnknownCommand, want: exitUsage},
+		// status of script is by error of failed command
+		{err: gomem.Fail(&gomem.ScriptError{Cmd: "x", Err: gomem.ErrUnknownCommand}), want: exitUsage},
+		{err: gomem.Fail(&gomem.ScriptError{Cmd: "x", Err: gomem.Failf("failed")}), want: exitFailure},
+		{err: gomem.Fail(&gomem.ScriptError{Err: errors.New("syntax")}), want: exitFailure},
+	}
+	for _, v := range tests {
+		if got := exitStatus(v.err); got != v.want {
+			t.Errorf("%#v: want %d but got %d", v.err, v.want, got)
+		}
+	}
+}
+
+func TestOneShot_ReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomemcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"title": "b"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	// lock is held by another session
+	holder, err := gomem.GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Close()
+
+	opt.format = "text"
+	for _, args := range [][]string{{"rm", "b"}, {"rmsub", "sub"}, {"mkdir", "new"}} {
+		gs, err := gomem.GomemsNewReadOnly(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		status := oneShot(strings.NewReader("yes\n"), out, errOut, gs, args)
+		if status != exitFailure || errOut.String() != gomem.ErrReadOnly.Error()+"\n" {
+			t.Errorf("%q: want read only failure but got %d %q %q", args, status, out, errOut)
+		}
+	}
+	for _, v := range []struct {
+		name   string
+		exists bool
+	}{{"b.json", true}, {"sub", true}, {"new", false}} {
+		if _, err := os.Stat(filepath.Join(dir, v.name)); os.IsNotExist(err) == v.exists {
+			t.Errorf("%s: want exists %v but got %v", v.name, v.exists, err)
+		}
+	}
+}
+
+func TestOneShot_RunExit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomemcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, ".test.gm")
+	data := "new a <<END\ntitle a\ncontent a\nEND\nexit\nshow a\n"
+	if err := ioutil.WriteFile(script, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, status := runOneShot(t, dir, "text", "", "run", script)
+	if status != exitOK || strings.Contains(stdout, "content a") || stderr != "" {
+		t.Errorf("want stop by exit but got %d %q %q", status, stdout, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.json")); err != nil {
+		t.Errorf("changes before exit are not written: %v", err)
+	}
+}
+
+func TestQuit_Cancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomemcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gs, err := gomem.GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.Close()
+	g, err := gomem.New(filepath.Join(dir, "a.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetDirty()
+	if err := gs.AddGomem(g); err != nil {
+		t.Fatal(err)
+	}
+	igs, noPrompt, interWriter = gs, true, &bytes.Buffer{}
+	for _, input := range []string{"", "cancel\n", "x\ny\n"} {
+		interReader = strings.NewReader(input)
+		if _, err := quit(); exitStatus(err) != exitFailure {
+			t.Errorf("%q: want failure but got %v", input, err)
+		}
+	}
+	interReader = strings.NewReader("discard\n")
+	if _, err := quit(); err != gomem.ErrValidExit {
+		t.Errorf("want exit by discard but got %v", err)
+	}
+}
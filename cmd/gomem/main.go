@@ -8,9 +8,11 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/kamisari/gomem"
+	"github.com/kamisari/gomem/lineedit"
 )
 
 const version = "0.0.0"
@@ -23,23 +25,49 @@ type option struct {
 	callback    string
 	interactive bool
 	conf        string
+	symlinks    bool
+	strict      bool
+	keymap      string
+	history     string
+	historySize int
+	histPrompts bool
+	format      string
+	color       string
 }
 
 var opt option
 
-func (opt *option) getAutoRunList() []string {
+// getConf return values of "key=value" in configuration file
+// entries in "[section]" are excluded, see getSection
+func (opt *option) getConf(key string) []string {
+	return opt.getSection("", key)
+}
+
+// getSection return values of "key=value" in "[name]" of configuration file
+// section continues until next "[section]", empty name is top level
+func (opt *option) getSection(name, key string) []string {
+	if opt.conf == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(opt.conf)
+	if err != nil {
+		return nil
+	}
 	var list []string
-	if opt.conf != "" {
-		b, err := ioutil.ReadFile(opt.conf)
-		if err != nil {
-			return nil
+	section := ""
+	for _, s := range strings.Fields(string(b)) {
+		if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+			section = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+			continue
 		}
-		for _, s := range strings.Fields(string(b)) {
-			if strings.HasPrefix(s, "autocmd=") {
-				list = append(list, strings.TrimPrefix(s, "autocmd="))
-			}
+		if section == name && strings.HasPrefix(s, key+"=") {
+			list = append(list, strings.TrimPrefix(s, key+"="))
 		}
 	}
+	return list
+}
+func (opt *option) getAutoRunList() []string {
+	list := opt.getConf("autocmd")
 	if opt.autocmd != "" {
 		list = append(list, strings.Fields(opt.autocmd)...)
 	}
@@ -52,6 +80,73 @@ func (opt *option) getCallbacks() []string {
 	return strings.Fields(opt.callback)
 }
 
+// initHistory resolve history options by flags, conf and default, for interactive session
+// if default path is unknown then history is in memory
+func (opt *option) initHistory() error {
+	if opt.history == "" {
+		if list := opt.getConf("history"); len(list) != 0 {
+			opt.history = list[len(list)-1]
+		}
+	}
+	if opt.history == "" {
+		path, err := gomem.DefaultHistoryPath()
+		if err != nil {
+			log.Printf("history: %v: history is in memory", err)
+			path = "none"
+		}
+		opt.history = path
+	}
+	if opt.historySize == 0 {
+		if list := opt.getConf("history-size"); len(list) != 0 {
+			n, err := strconv.Atoi(list[len(list)-1])
+			if err != nil {
+				return fmt.Errorf("invalid history-size: %v", err)
+			}
+			opt.historySize = n
+		}
+	}
+	if opt.historySize < 0 {
+		return fmt.Errorf("invalid history-size: %d", opt.historySize)
+	}
+	if list := opt.getConf("history-prompts"); len(list) != 0 && !opt.histPrompts {
+		opt.histPrompts = list[len(list)-1] == "true"
+	}
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `usage: gomem [flags] [subcommand [args...]]
+
+without subcommand, run -autocmd and exit, or start session by -interactive.
+with subcommand, run it once without prompt and write changes.
+flags must be before subcommand.
+exit status of subcommand: 0 success, 1 failure, 2 unknown subcommand or invalid arguments
+-format json or ndjson writes results as JSON without color,
+and error of subcommand as {"error": message, "status": exit status} to stderr
+
+gomem run script.gm [args...] runs commands in file, one command per line:
+	# comment
+	name=value        variable, $name ${name}, $1 to $9 for args
+	set +e            continue on error, set -e for stop, default is stop
+	new memo <<EOF    lines until EOF are input of prompts, <<'EOF' for no expansion
+	my title
+	my content
+	EOF
+changes are written after script as other subcommands
+
+colors of text output are changed by [theme] section in -conf file:
+	[theme]
+	key=hiblue
+	title=yellow,bold
+	content=none
+roles are key (default green), title (magenta) and content (cyan),
+value is comma separated colors and attributes: `+strings.Join(colorNames(), " ")+`
+
+flags:
+`)
+	flag.PrintDefaults()
+}
+
 // TODO: be graceful
 func (opt *option) init() error {
 	flag.BoolVar(&opt.version, "version", false, "")
@@ -61,14 +156,49 @@ func (opt *option) init() error {
 	flag.BoolVar(&opt.interactive, "interactive", false, "")
 	flag.BoolVar(&opt.interactive, "i", false, "alias of interactive")
 	flag.StringVar(&opt.conf, "conf", "", "path to configuration file")
+	flag.BoolVar(&opt.symlinks, "follow-symlinks", false, "follow symbolic links in workdir")
+	flag.BoolVar(&opt.strict, "strict", false, "exit if malformed json in workdir")
+	flag.StringVar(&opt.keymap, "keymap", "", "line editor keymap: emacs or vi, default is keymap= in conf or emacs")
+	flag.StringVar(&opt.history, "history", "", "path to history file, \"none\" for no file, default is history= in conf or gomem/history in user config directory")
+	flag.IntVar(&opt.historySize, "history-size", 0, "max lines of history, default is history-size= in conf or "+strconv.Itoa(gomem.DefaultHistorySize))
+	flag.BoolVar(&opt.histPrompts, "history-prompts", false, "record input of title and content prompts to history, or history-prompts=true in conf")
+	flag.StringVar(&opt.format, "format", "", "output format: text, json or ndjson, default is format= in conf or text")
+	flag.StringVar(&opt.color, "color", "", "color of text output: auto, always or never, default is color= in conf or auto, auto is never if NO_COLOR is set or stdout is not terminal")
+	flag.Usage = usage
 	flag.Parse()
-	if flag.NArg() != 0 {
-		return fmt.Errorf("invalid args: %q", flag.Args())
+	if flag.NArg() != 0 && opt.interactive {
+		return fmt.Errorf("invalid args: %q: subcommand is not accepted with -interactive", flag.Args())
 	}
 	if opt.version {
 		fmt.Printf("version %s\n", version)
 		os.Exit(0)
 	}
+	if opt.keymap == "" {
+		if list := opt.getConf("keymap"); len(list) != 0 {
+			opt.keymap = list[len(list)-1]
+		}
+	}
+	switch opt.keymap {
+	case "", "emacs", "vi":
+	default:
+		return fmt.Errorf("invalid keymap: %q: require %s", opt.keymap, strings.Join(lineedit.Keymaps, " or "))
+	}
+	if opt.format == "" {
+		if list := opt.getConf("format"); len(list) != 0 {
+			opt.format = list[len(list)-1]
+		}
+	}
+	if opt.format == "" {
+		opt.format = "text"
+	}
+	if _, ok := gomem.Renderers[opt.format]; !ok {
+		return fmt.Errorf("invalid format: %q: require %s", opt.format, strings.Join(gomem.Formats(), ", "))
+	}
+	if err := opt.initColor(); err != nil {
+		return err
+	}
+	gomem.FollowSymlinks = opt.symlinks
+	gomem.Tolerant = !opt.strict
 	// default work directory
 	if opt.workdir == "" {
 		u, err := user.Current()
@@ -91,18 +221,47 @@ func main() {
 	if err := opt.init(); err != nil {
 		log.Fatal(err)
 	}
+	// for relative path of run
+	if wd, err := os.Getwd(); err == nil {
+		startDir = wd
+	}
 	// reconsider: needs it?
 	if err := os.Chdir(opt.workdir); err != nil {
 		log.Fatal(err)
 	}
 
 	gs, err := gomem.GomemsNew(opt.workdir)
+	if gomem.IsLocked(err) {
+		log.Println(err)
+		log.Println("open as read only session")
+		gs, err = gomem.GomemsNewReadOnly(opt.workdir)
+	}
+	if _, ok := err.(*gomem.LoadError); ok && gs != nil {
+		// without -strict, failed files are not included
+		log.Println(err)
+		err = nil
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if flag.NArg() != 0 {
+		status := oneShot(os.Stdin, os.Stdout, os.Stderr, gs, flag.Args())
+		if err := igs.Close(); err != nil {
+			log.Println(err)
+		}
+		os.Exit(status)
+	}
+
+	if err := opt.initHistory(); err != nil {
+		log.Fatal(err)
+	}
 	log.Println("autocmd:", opt.getAutoRunList())
 	err = interactive(os.Stdin, os.Stdout, "gomem:> ", gs, opt.getAutoRunList(), opt.getCallbacks())
+	// igs is exchanged by cd
+	if cerr := igs.Close(); cerr != nil {
+		log.Println(cerr)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,616 @@
+package main
+
+// results of commands
+//
+// each command returns a result value, SubCommands renders it by -format:
+//   text   String of result, colored text for terminal, default
+//   json   one indented JSON value per command
+//   ndjson array is written as one element per line, other value as one line
+// color is disabled in json and ndjson, prompts are written to stderr
+// error of one-shot command is written to stderr as {"error": message, "status": exit status}
+//
+// schema, optional fields are omitted if empty, times are RFC 3339
+//
+//   entry: la, ls, show, info, tag, mod, append, trim, check, todo, done, start,
+//   cancel, reopen, due, remind, priority, repeat
+//     {"key", "title", "content": [string], "tags": [string], "id"?, "created"?,
+//      "updated"?, "meta"?: {}, "readonly": bool, "unsaved": bool, "todo"?: todo}
+//   todo:
+//     {"status": "open|in-progress|done|cancelled", "priority"?: "A-E",
+//      "due"?, "due_state": "overdue|today|upcoming|none", "remind"?,
+//      "reminded": bool, "completed"?, "repeat"?: rule, "completions"?: int,
+//      "last_completed"?, "items": [{"text", "done": bool}]}
+//   la, ls, todo without name: [entry]
+//   state: {"dir", "readonly": bool, "subcategories": [string], "entries": [entry], "broken": [broken]}
+//   broken: show, repair
+//     {"key", "error"}
+//   search: [{"key", "title", "score"?, "title_spans": [[start, end]],
+//             "lines": [{"line", "text", "spans": [[start, end]]}]}]
+//     line is from 1, spans are byte offsets of hits
+//   agenda: {"overdue": [entry], "days": [{"date": "2006-01-02", "todos": [entry]}]}
+//   write, :wq: [{"key", "status": "written|reloaded|skipped|error", "error"?}],
+//     key is ".gomem" for failure of search index
+//   migrate: {"outdated": [{"key", "version", "target", "steps": [{"from", "description"}],
+//             "error"?}], "broken": [broken], "dry_run": bool}
+//   other commands: {"message", "key"?}
+//   canceled command, declined confirm or no answer of prompt:
+//     {"error": "canceled", "status": 1}, "stop write" or "stop migrate" for write and migrate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/kamisari/gomem"
+)
+
+// textNoColor color.NoColor of text format, restored by setFormat
+var textNoColor = color.NoColor
+
+// setFormat change format of sub and opt.format
+// color is disabled and prompts are written to stderr except text
+func setFormat(sub *gomem.SubCommands, format string) error {
+	if err := sub.SetFormat(format); err != nil {
+		return err
+	}
+	opt.format = format
+	color.NoColor = textNoColor || format != "text"
+	sub.PromptWriter = nil
+	if format != "text" {
+		sub.PromptWriter = interErrWriter
+	}
+	return nil
+}
+
+// errorOutput render err of one-shot command
+func errorOutput(err error, status int) string {
+	if opt.format == "text" {
+		return err.Error()
+	}
+	s, _ := gomem.RenderNDJSON(struct {
+		Error  string `json:"error"`
+		Status int    `json:"status"`
+	}{Error: err.Error(), Status: status})
+	return s
+}
+
+// message result of command without data
+type message struct {
+	Message string `json:"message"`
+	Key     string `json:"key,omitempty"`
+}
+
+func (m message) String() string {
+	return m.Message
+}
+
+/// entry ///
+
+// entry gomem
+type entry struct {
+	Key      string                 `json:"key"`
+	Title    string                 `json:"title"`
+	Content  []string               `json:"content"`
+	Tags     []string               `json:"tags"`
+	ID       string                 `json:"id,omitempty"`
+	Created  *time.Time             `json:"created,omitempty"`
+	Updated  *time.Time             `json:"updated,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+	ReadOnly bool                   `json:"readonly"`
+	Unsaved  bool                   `json:"unsaved"`
+	Todo     *todoEntry             `json:"todo,omitempty"`
+}
+
+// todoEntry todo of entry
+type todoEntry struct {
+	Status        gomem.Status `json:"status"`
+	Priority      string       `json:"priority,omitempty"`
+	Due           *time.Time   `json:"due,omitempty"`
+	DueState      string       `json:"due_state"`
+	Remind        *time.Time   `json:"remind,omitempty"`
+	Reminded      bool         `json:"reminded"`
+	Completed     *time.Time   `json:"completed,omitempty"`
+	Repeat        string       `json:"repeat,omitempty"`
+	Completions   int          `json:"completions,omitempty"`
+	LastCompleted *time.Time   `json:"last_completed,omitempty"`
+	Items         []item       `json:"items"`
+
+	dueState gomem.DueState
+}
+
+type item struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+// dueStates name of gomem.DueState in schema
+var dueStates = map[gomem.DueState]string{
+	gomem.DueOverdue:  "overdue",
+	gomem.DueToday:    "today",
+	gomem.DueUpcoming: "upcoming",
+	gomem.DueNone:     "none",
+}
+
+// newEntry return entry of igs.Gmap[key], now is for due state and reminder
+func newEntry(key string, now time.Time) entry {
+	g := igs.Gmap[key]
+	e := entry{
+		Key:      key,
+		Title:    g.J.Title,
+		Content:  append([]string{}, g.J.Content...),
+		Tags:     append([]string{}, g.J.Tags...),
+		ID:       g.J.ID,
+		Created:  g.J.Created,
+		Updated:  g.J.Updated,
+		Meta:     g.J.Meta,
+		ReadOnly: !g.Override,
+		Unsaved:  g.IsDirty(),
+	}
+	if t := g.J.Todo; t != nil {
+		st := t.DueState(now)
+		e.Todo = &todoEntry{
+			Status:    t.Status,
+			Priority:  t.Priority.String(),
+			Due:       t.Due,
+			DueState:  dueStates[st],
+			Remind:    t.Remind,
+			Reminded:  t.IsReminded(now),
+			Completed: t.Completed,
+			Items:     []item{},
+			dueState:  st,
+		}
+		if t.Recur != nil {
+			e.Todo.Repeat = t.Recur.String()
+		}
+		if n := len(t.History); n != 0 {
+			e.Todo.Completions = n
+			e.Todo.LastCompleted = &t.History[n-1].Done
+		}
+		for _, v := range t.Items {
+			e.Todo.Items = append(e.Todo.Items, item{Text: v.Text, Done: v.Done})
+		}
+	}
+	return e
+}
+
+// newEntries return entries of keys
+func newEntries(keys []string) []entry {
+	now := time.Now()
+	list := make([]entry, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, newEntry(key, now))
+	}
+	return list
+}
+
+// lines return content or text of items
+func (e entry) lines() []string {
+	if e.Todo == nil {
+		return e.Content
+	}
+	lines := make([]string, 0, len(e.Todo.Items))
+	for _, v := range e.Todo.Items {
+		lines = append(lines, v.Text)
+	}
+	return lines
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+func formatTags(tags []string) string {
+	var str string
+	for _, tag := range tags {
+		str += " #" + tag
+	}
+	return str
+}
+func formatDue(t *todoEntry) string {
+	var str string
+	if t.Due != nil {
+		str += color.HiYellowString(" due:%s", t.Due.Format("2006-01-02 Mon"))
+	}
+	if t.Repeat != "" {
+		str += color.HiYellowString(" repeat:%s", t.Repeat)
+	}
+	return str
+}
+func formatPriority(t *todoEntry) string {
+	if t.Priority == "" {
+		return ""
+	}
+	return color.HiRedString("(%s)", t.Priority)
+}
+func formatTodo(e entry) string {
+	t := e.Todo
+	str := formatPriority(t)
+	str += titleString("[ %s ]:%s", e.Title, t.Status)
+	str += fmt.Sprintf(" created:%s", formatTime(e.Created))
+	if t.Completed != nil {
+		str += fmt.Sprintf(" completed:%s", formatTime(t.Completed))
+	}
+	str += formatDue(t)
+	if t.Remind != nil {
+		str += color.HiRedString(" remind:%s", formatTime(t.Remind))
+	}
+	if t.Completions != 0 {
+		str += fmt.Sprintf(" done %d times, last:%s", t.Completions, formatTime(t.LastCompleted))
+	}
+	return str + "\n" + formatItems(e)
+}
+func formatItems(e entry) string {
+	var str string
+	for _, v := range e.Todo.Items {
+		if v.Done {
+			str += contentString("\t[x] %s\n", v.Text)
+			continue
+		}
+		str += contentString("\t[ ] %s\n", v.Text)
+	}
+	return str
+}
+
+// entryList result of la
+type entryList []entry
+
+func (l entryList) String() string {
+	var str string
+	for _, e := range l {
+		str += keyString("----- %s -----\n", e.Key)
+		str += titleString("[ %s ]", e.Title)
+		str += color.HiBlueString("%s\n", formatTags(e.Tags))
+		str += contentString("%s\n", strings.Join(e.lines(), "\n"))
+	}
+	return str
+}
+
+// keyList result of ls
+type keyList []entry
+
+func (l keyList) String() string {
+	var str string
+	for _, e := range l {
+		str += keyString("%s\n", e.Key)
+	}
+	return str
+}
+
+// contentView result of show
+type contentView entry
+
+func (v contentView) String() string {
+	if v.Todo != nil {
+		return formatTodo(entry(v))
+	}
+	return contentString("%s\n", strings.Join(v.Content, "\n"))
+}
+
+// infoView result of info
+type infoView entry
+
+func (v infoView) String() string {
+	str := keyString("key:%s\n", v.Key)
+	str += titleString("title:%s\n", v.Title)
+	str += fmt.Sprintf("id:%s\n", v.ID)
+	str += fmt.Sprintf("created:%s\n", formatTime(v.Created))
+	str += fmt.Sprintf("updated:%s\n", formatTime(v.Updated))
+	str += color.HiBlueString("tags:%s\n", formatTags(v.Tags))
+	for _, k := range sortedMetaKeys(v.Meta) {
+		str += fmt.Sprintf("meta:%s:%v\n", k, v.Meta[k])
+	}
+	return str
+}
+
+// tagView result of tag
+type tagView entry
+
+func (v tagView) String() string {
+	return keyString("%s:", v.Key) + color.HiBlueString("%s", formatTags(v.Tags))
+}
+
+// itemsView result of trim and check
+type itemsView entry
+
+func (v itemsView) String() string {
+	return formatItems(entry(v))
+}
+
+// todoView result of changed todo, header is text before todo
+type todoView struct {
+	entry
+	header string
+}
+
+func (v todoView) String() string {
+	return v.header + formatTodo(v.entry)
+}
+
+// todoList result of todo without name
+type todoList []entry
+
+func (l todoList) String() string {
+	groups := make(map[gomem.DueState]string)
+	var closed, reminded string
+	for _, e := range l {
+		t := e.Todo
+		if t.Status.IsClosed() {
+			closed += keyString("%s:", e.Key)
+			closed += color.RedString("[ %s ]:%s\n", e.Title, t.Status)
+			closed += formatItems(e)
+			continue
+		}
+		if t.Reminded {
+			reminded += color.HiRedString("reminder:%s:[ %s ]:%s\n", e.Key, e.Title, formatTime(t.Remind))
+		}
+		st := t.dueState
+		groups[st] += keyString("%s:", e.Key)
+		groups[st] += formatPriority(t)
+		groups[st] += titleString("[ %s ]:%s", e.Title, t.Status)
+		groups[st] += formatDue(t) + "\n"
+		groups[st] += formatItems(e) + "\n"
+	}
+	str := reminded
+	for _, st := range []gomem.DueState{gomem.DueOverdue, gomem.DueToday, gomem.DueUpcoming, gomem.DueNone} {
+		if groups[st] == "" {
+			continue
+		}
+		str += color.HiYellowString("----- %s -----\n", st) + groups[st]
+	}
+	if closed != "" {
+		str += color.HiYellowString("----- closed -----\n") + closed
+	}
+	return str
+}
+
+// agendaView result of agenda
+type agendaView struct {
+	Overdue []entry     `json:"overdue"`
+	Days    []agendaDay `json:"days"`
+}
+
+type agendaDay struct {
+	Date  string  `json:"date"`
+	Todos []entry `json:"todos"`
+
+	day time.Time
+}
+
+func (v agendaView) String() string {
+	format := func(e entry) string {
+		return keyString("\t%s:", e.Key) + formatPriority(e.Todo) +
+			titleString("[ %s ]:%s\n", e.Title, e.Todo.Status)
+	}
+	var str string
+	if len(v.Overdue) != 0 {
+		str += color.HiRedString("overdue\n")
+		for _, e := range v.Overdue {
+			str += format(e)
+		}
+	}
+	for _, d := range v.Days {
+		str += color.HiYellowString("%s\n", d.day.Format("2006-01-02 Mon"))
+		for _, e := range d.Todos {
+			str += format(e)
+		}
+	}
+	return str
+}
+
+/// state ///
+
+// brokenView broken file
+type brokenView struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+func newBrokenViews() []brokenView {
+	list := []brokenView{}
+	for _, key := range igs.BrokenKeys() {
+		list = append(list, brokenView{Key: key, Error: igs.Broken[key].Error()})
+	}
+	return list
+}
+
+func (v brokenView) String() string {
+	return color.RedString("broken:%s\n", v.Error) + "use repair " + keyString(v.Key)
+}
+
+// stateView result of state
+type stateView struct {
+	Dir           string       `json:"dir"`
+	ReadOnly      bool         `json:"readonly"`
+	Subcategories []string     `json:"subcategories"`
+	Entries       []entry      `json:"entries"`
+	Broken        []brokenView `json:"broken"`
+}
+
+func (v stateView) String() string {
+	var str string
+	str += keyString("igs.dir:%s\n", v.Dir)
+	if v.ReadOnly {
+		str += color.RedString("read only session: locked by another session\n")
+	}
+	for _, name := range v.Subcategories {
+		str += color.HiGreenString("sub categories:%s\n", name)
+	}
+	for _, e := range v.Entries {
+		str += keyString("%s:", e.Key)
+		str += titleString("[ %s ]:", e.Title)
+		str += fmt.Sprint("read only ")
+		if e.ReadOnly {
+			str += color.RedString("%v", e.ReadOnly)
+		} else {
+			str += color.HiCyanString("%v", e.ReadOnly)
+		}
+		if e.Unsaved {
+			str += color.HiRedString(":unsaved")
+		}
+		str += "\n"
+	}
+	for _, b := range v.Broken {
+		str += keyString("%s:", b.Key)
+		str += color.RedString("broken:%s\n", b.Error)
+	}
+	return str
+}
+
+/// search ///
+
+type match struct {
+	Key        string      `json:"key"`
+	Title      string      `json:"title"`
+	Score      float64     `json:"score,omitempty"`
+	TitleSpans [][2]int    `json:"title_spans"`
+	Lines      []lineMatch `json:"lines"`
+}
+
+type lineMatch struct {
+	Line  int      `json:"line"`
+	Text  string   `json:"text"`
+	Spans [][2]int `json:"spans"`
+}
+
+func newMatch(r *gomem.SearchResult) match {
+	spans := func(s [][2]int) [][2]int {
+		return append([][2]int{}, s...)
+	}
+	m := match{Key: r.Key, Title: r.Title, Score: r.Score, TitleSpans: spans(r.TitleSpans), Lines: []lineMatch{}}
+	for _, l := range r.Lines {
+		m.Lines = append(m.Lines, lineMatch{Line: l.Index + 1, Text: l.Text, Spans: spans(l.Spans)})
+	}
+	return m
+}
+
+// matchList result of search
+type matchList []match
+
+func (l matchList) String() string {
+	var str string
+	for _, m := range l {
+		str += keyString("%s:", m.Key)
+		if m.Score != 0 {
+			str += color.YellowString("(%.2f)", m.Score)
+		}
+		str += titleString("[ ") + highlight(m.Title, m.TitleSpans, titleString) + titleString(" ]\n")
+		for _, l := range m.Lines {
+			str += fmt.Sprintf("\t%d: ", l.Line) + highlight(l.Text, l.Spans, contentString) + "\n"
+		}
+	}
+	return str
+}
+
+// highlight hits in s
+func highlight(s string, spans [][2]int, base func(string, ...interface{}) string) string {
+	var str string
+	prev := 0
+	for _, sp := range spans {
+		str += base("%s", s[prev:sp[0]])
+		str += color.New(color.FgHiYellow, color.Bold, color.Underline).Sprint(s[sp[0]:sp[1]])
+		prev = sp[1]
+	}
+	return str + base("%s", s[prev:])
+}
+
+/// write ///
+
+// written result of write for key
+type written struct {
+	Key    string `json:"key"`
+	Status string `json:"status"` // written, reloaded, skipped or error
+	Error  string `json:"error,omitempty"`
+}
+
+// writeReport result of write
+type writeReport []written
+
+func (r writeReport) String() string {
+	var str string
+	for _, w := range r {
+		switch w.Status {
+		case "error":
+			str += color.RedString("err:%s:%s\n", w.Key, w.Error)
+		case "skipped":
+			str += color.RedString("%s:%s\n", w.Status, w.Key)
+		default:
+			str += keyString("%s:%s\n", w.Status, w.Key)
+		}
+	}
+	return str
+}
+
+// ok return false if any failed or skipped
+func (r writeReport) ok() bool {
+	for _, w := range r {
+		if w.Status == "error" || w.Status == "skipped" {
+			return false
+		}
+	}
+	return true
+}
+
+/// migrate ///
+
+type outdated struct {
+	Key     string `json:"key"`
+	Version int    `json:"version"`
+	Target  int    `json:"target"`
+	Steps   []step `json:"steps"`
+	Error   string `json:"error,omitempty"`
+}
+
+type step struct {
+	From        int    `json:"from"`
+	Description string `json:"description"`
+}
+
+// migrationView result of migrate
+type migrationView struct {
+	Outdated []outdated   `json:"outdated"`
+	Broken   []brokenView `json:"broken"`
+	DryRun   bool         `json:"dry_run"`
+}
+
+func newMigrationView(keys []string, dryRun bool) migrationView {
+	v := migrationView{Outdated: []outdated{}, Broken: newBrokenViews(), DryRun: dryRun}
+	for _, key := range keys {
+		g := igs.Gmap[key]
+		o := outdated{Key: key, Version: g.Version(), Target: gomem.SchemaVersion, Steps: []step{}}
+		path, err := gomem.MigrationPath(g.Version())
+		if err != nil {
+			o.Error = err.Error()
+		}
+		for _, m := range path {
+			o.Steps = append(o.Steps, step{From: m.From, Description: m.Description})
+		}
+		v.Outdated = append(v.Outdated, o)
+	}
+	return v
+}
+
+func (v migrationView) String() string {
+	var str string
+	for _, o := range v.Outdated {
+		str += keyString("%s:", o.Key)
+		str += fmt.Sprintf("version %d -> %d\n", o.Version, o.Target)
+		if o.Error != "" {
+			str += color.RedString("\t%s\n", o.Error)
+			continue
+		}
+		for _, s := range o.Steps {
+			str += contentString("\t%d: %s\n", s.From, s.Description)
+		}
+	}
+	for _, b := range v.Broken {
+		str += keyString("%s:", b.Key) + color.RedString("broken, skip:%s\n", b.Error)
+	}
+	if v.DryRun {
+		str += "dry run: " + strconv.Itoa(len(v.Outdated)) + " files to rewrite"
+	}
+	return str
+}
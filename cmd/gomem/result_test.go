@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixtures of TestOneShot_JSON, times are fixed for golden output
+var jsonFixtures = map[string]string{
+	"memo.json": `{"version": 2, "title": "memo title", "content": ["line 1", "line 2"],
+		"id": "m1", "created": "2001-02-03T04:05:06Z", "updated": "2001-02-04T04:05:06Z",
+		"tags": ["a", "b"], "meta": {"source": "test"}}`,
+	"todo/task.json": `{"version": 2, "title": "task", "id": "t1",
+		"todo": {"status": "open", "priority": 1, "due": "2001-02-03T00:00:00Z",
+		"recur": {"every": "week", "weekday": "Monday"},
+		"history": [{"due": "2001-01-27T00:00:00Z", "done": "2001-01-28T10:00:00Z"}],
+		"items": [{"text": "first", "done": true}, {"text": "second"}]}}`,
+}
+
+func TestOneShot_JSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomemcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, s := range jsonFixtures {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(s), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		format string
+		input  string
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{format: "json", args: []string{"show", "memo"}, stdout: `{
+  "key": "memo.json",
+  "title": "memo title",
+  "content": [
+    "line 1",
+    "line 2"
+  ],
+  "tags": [
+    "a",
+    "b"
+  ],
+  "id": "m1",
+  "created": "2001-02-03T04:05:06Z",
+  "updated": "2001-02-04T04:05:06Z",
+  "meta": {
+    "source": "test"
+  },
+  "readonly": false,
+  "unsaved": false
+}
+`},
+		{format: "json", args: []string{"show", "todo/task"}, stdout: `{
+  "key": "todo/task.json",
+  "title": "task",
+  "content": [],
+  "tags": [],
+  "id": "t1",
+  "readonly": false,
+  "unsaved": false,
+  "todo": {
+    "status": "open",
+    "priority": "A",
+    "due": "2001-02-03T00:00:00Z",
+    "due_state": "overdue",
+    "reminded": false,
+    "repeat": "weekly:monday",
+    "completions": 1,
+    "last_completed": "2001-01-28T10:00:00Z",
+    "items": [
+      {
+        "text": "first",
+        "done": true
+      },
+      {
+        "text": "second",
+        "done": false
+      }
+    ]
+  }
+}
+`},
+		{format: "ndjson", args: []string{"ls"}, stdout: `{"key":"memo.json","title":"memo title","content":["line 1","line 2"],"tags":["a","b"],"id":"m1","created":"2001-02-03T04:05:06Z","updated":"2001-02-04T04:05:06Z","meta":{"source":"test"},"readonly":false,"unsaved":false}
+{"key":"todo/task.json","title":"task","content":[],"tags":[],"id":"t1","readonly":false,"unsaved":false,"todo":{"status":"open","priority":"A","due":"2001-02-03T00:00:00Z","due_state":"overdue","reminded":false,"repeat":"weekly:monday","completions":1,"last_completed":"2001-01-28T10:00:00Z","items":[{"text":"first","done":true},{"text":"second","done":false}]}}
+`},
+		// prompts are not written to stdout
+		{format: "json", input: "new title\nnew content\n", args: []string{"new", "bar"}, stdout: `{
+  "message": "new gomem included",
+  "key": "bar.json"
+}
+`},
+		// errors are written to stderr
+		{format: "json", args: []string{"rm", "memo"}, status: exitFailure,
+			stderr: `{"error":"canceled","status":1}` + "\n"},
+		{format: "ndjson", args: []string{"show", "nothing"}, status: exitFailure,
+			stderr: `{"error":"not found:nothing","status":1}` + "\n"},
+		{format: "json", args: []string{"nothing"}, status: exitUsage,
+			stderr: `{"error":"unknown command: nothing","status":2}` + "\n"},
+	}
+	for _, v := range tests {
+		stdout, stderr, status := runOneShot(t, dir, v.format, v.input, v.args...)
+		if status != v.status || stdout != v.stdout || stderr != v.stderr {
+			t.Errorf("%s %q: want %d\n%s%s\nbut got %d\n%s%s",
+				v.format, v.args, v.status, v.stdout, v.stderr, status, stdout, stderr)
+		}
+	}
+}
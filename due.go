@@ -0,0 +1,134 @@
+package gomem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DueState group of todo by due date
+type DueState int
+
+// DueState values
+const (
+	DueNone DueState = iota
+	DueOverdue
+	DueToday
+	DueUpcoming
+)
+
+func (d DueState) String() string {
+	switch d {
+	case DueOverdue:
+		return "overdue"
+	case DueToday:
+		return "due today"
+	case DueUpcoming:
+		return "upcoming"
+	}
+	return "no date"
+}
+
+// dateLayouts accepted by ParseDate
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04",
+	"2006/01/02",
+}
+
+// ParseDate parse date of todo relative to now
+// accept "2006-01-02", "2006-01-02T15:04", "2006/01/02",
+// "today", "tomorrow", "yesterday", weekday name e.g. "monday" for next monday,
+// and "+3d", "+2w", "+1m" for days, weeks, months after today
+// result without time is 00:00 in now.Location()
+func ParseDate(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	s = strings.ToLower(s)
+	today := TruncateDay(now)
+	switch s {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] {
+			days := (int(wd) - int(today.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			return today.AddDate(0, 0, days), nil
+		}
+	}
+	if strings.HasPrefix(s, "+") && len(s) > 2 {
+		n, err := strconv.Atoi(s[1 : len(s)-1])
+		if err == nil && n >= 0 {
+			switch s[len(s)-1] {
+			case 'd':
+				return today.AddDate(0, 0, n), nil
+			case 'w':
+				return today.AddDate(0, 0, 7*n), nil
+			case 'm':
+				return today.AddDate(0, n, 0), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: require 2006-01-02, today, tomorrow, weekday or +Nd, +Nw, +Nm", s)
+}
+
+// TruncateDay return 00:00 of t in t.Location()
+func TruncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// DueState return group of t by Due at now
+// closed todo is DueNone
+func (t *Todo) DueState(now time.Time) DueState {
+	if t.Due == nil || t.Status.IsClosed() {
+		return DueNone
+	}
+	due := TruncateDay(t.Due.In(now.Location()))
+	today := TruncateDay(now)
+	switch {
+	case due.Before(today):
+		return DueOverdue
+	case due.Equal(today):
+		return DueToday
+	}
+	return DueUpcoming
+}
+
+// IsReminded return true if Remind is passed and todo is not closed
+func (t *Todo) IsReminded(now time.Time) bool {
+	return t.Remind != nil && !t.Status.IsClosed() && !t.Remind.After(now)
+}
+
+// SetDue set due date of todo, nil for clear
+func (g *Gomem) SetDue(due *time.Time) error {
+	if !g.IsTodo() {
+		return g.errNotTodo()
+	}
+	g.J.Todo.Due = due
+	g.SetDirty()
+	return nil
+}
+
+// SetRemind set reminder time of todo, nil for clear
+func (g *Gomem) SetRemind(remind *time.Time) error {
+	if !g.IsTodo() {
+		return g.errNotTodo()
+	}
+	g.J.Todo.Remind = remind
+	g.SetDirty()
+	return nil
+}
@@ -0,0 +1,85 @@
+package gomem
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	// Wednesday
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "2026-11-01", want: "2026-11-01 00:00"},
+		{in: "2026/11/01", want: "2026-11-01 00:00"},
+		{in: "2026-11-01T09:15", want: "2026-11-01 09:15"},
+		{in: "today", want: "2026-10-14 00:00"},
+		{in: "Tomorrow", want: "2026-10-15 00:00"},
+		{in: "yesterday", want: "2026-10-13 00:00"},
+		{in: "+3d", want: "2026-10-17 00:00"},
+		{in: "+2w", want: "2026-10-28 00:00"},
+		{in: "+1m", want: "2026-11-14 00:00"},
+		{in: "friday", want: "2026-10-16 00:00"},
+		{in: "mon", want: "2026-10-19 00:00"},
+		{in: "wednesday", want: "2026-10-21 00:00"}, // next week
+		{in: "+d", wantErr: true},
+		{in: "+3y", wantErr: true},
+		{in: "someday", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, v := range tests {
+		got, err := ParseDate(v.in, now)
+		if v.wantErr {
+			if err == nil {
+				t.Errorf("%q: want error but got %v", v.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", v.in, err)
+			continue
+		}
+		if s := got.Format("2006-01-02 15:04"); s != v.want {
+			t.Errorf("%q: want %s but got %s", v.in, v.want, s)
+		}
+	}
+}
+
+func TestTodo_DueState(t *testing.T) {
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+	date := func(s string) *time.Time {
+		d, err := ParseDate(s, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	tests := []struct {
+		todo Todo
+		want DueState
+	}{
+		{todo: Todo{Status: StatusOpen}, want: DueNone},
+		{todo: Todo{Status: StatusOpen, Due: date("yesterday")}, want: DueOverdue},
+		{todo: Todo{Status: StatusInProgress, Due: date("today")}, want: DueToday},
+		{todo: Todo{Status: StatusOpen, Due: date("2026-10-14T23:59")}, want: DueToday},
+		{todo: Todo{Status: StatusOpen, Due: date("+3d")}, want: DueUpcoming},
+		{todo: Todo{Status: StatusDone, Due: date("yesterday")}, want: DueNone},
+	}
+	for i, v := range tests {
+		if got := v.todo.DueState(now); got != v.want {
+			t.Errorf("%d: want %v but got %v", i, v.want, got)
+		}
+	}
+
+	remind := Todo{Status: StatusOpen, Remind: date("2026-10-14T15:00")}
+	if !remind.IsReminded(now) {
+		t.Error("want reminded")
+	}
+	remind.Remind = date("2026-10-14T16:00")
+	if remind.IsReminded(now) {
+		t.Error("want not reminded yet")
+	}
+}
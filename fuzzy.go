@@ -0,0 +1,133 @@
+package gomem
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// KeyMatch result of Lookup
+type KeyMatch struct {
+	Key         string   // resolved key, empty if not resolved
+	Candidates  []string // ambiguous matches, sorted by key
+	Suggestions []string // similar keys for "did you mean", sorted by distance
+}
+
+// maxSuggestions limit of KeyMatch.Suggestions
+const maxSuggestions = 5
+
+// Lookup resolve query to key of gs.Gmap
+// exact key with or without ".json" is resolved,
+// then exact base name, then unique prefix of key, base name or title.
+// if prefix is ambiguous then return Candidates,
+// if no prefix then Candidates are keys or titles that contain query
+// if no Candidates then Suggestions are keys in small edit distance
+// filter limits keys, nil for all keys
+// comparison except exact key is case-insensitive
+func (gs *Gomems) Lookup(query string, filter func(key string, g *Gomem) bool) KeyMatch {
+	var keys []string
+	for key, g := range gs.Gmap {
+		if filter == nil || filter(key, g) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	q := strings.TrimSuffix(query, ".json")
+	for _, key := range keys {
+		if key == q+".json" || key == query {
+			return KeyMatch{Key: key}
+		}
+	}
+	lq := strings.ToLower(q)
+	if lq == "" {
+		return KeyMatch{}
+	}
+	var bases, prefixes, contains []string
+	for _, key := range keys {
+		name := strings.ToLower(strings.TrimSuffix(key, ".json"))
+		base := filepath.Base(name)
+		title := strings.ToLower(gs.Gmap[key].J.Title)
+		switch {
+		case base == lq:
+			bases = append(bases, key)
+		case strings.HasPrefix(name, lq) || strings.HasPrefix(base, lq) || strings.HasPrefix(title, lq):
+			prefixes = append(prefixes, key)
+		case strings.Contains(name, lq) || strings.Contains(title, lq):
+			contains = append(contains, key)
+		}
+	}
+	for _, matched := range [][]string{bases, prefixes} {
+		switch len(matched) {
+		case 0:
+			continue
+		case 1:
+			return KeyMatch{Key: matched[0]}
+		}
+		return KeyMatch{Candidates: matched}
+	}
+	if len(contains) != 0 {
+		return KeyMatch{Candidates: contains}
+	}
+	return KeyMatch{Suggestions: gs.suggest(lq, keys)}
+}
+
+// suggest return keys that name, base name or title is near to lq
+// threshold is 1/3 of query length, at least 2
+func (gs *Gomems) suggest(lq string, keys []string) []string {
+	limit := len([]rune(lq)) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	dist := make(map[string]int)
+	var near []string
+	for _, key := range keys {
+		name := strings.ToLower(strings.TrimSuffix(key, ".json"))
+		d := -1
+		for _, s := range []string{name, filepath.Base(name), strings.ToLower(gs.Gmap[key].J.Title)} {
+			if n := levenshtein(lq, s); d == -1 || n < d {
+				d = n
+			}
+		}
+		if d <= limit {
+			dist[key] = d
+			near = append(near, key)
+		}
+	}
+	sort.SliceStable(near, func(i, j int) bool { return dist[near[i]] < dist[near[j]] })
+	if len(near) > maxSuggestions {
+		near = near[:maxSuggestions]
+	}
+	return near
+}
+
+// levenshtein return edit distance of a and b by runes
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,74 @@
+package gomem
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGomems_Lookup(t *testing.T) {
+	gs := &Gomems{Gmap: map[string]*Gomem{
+		"memo.json":                              {J: JSON{Title: "Memo"}},
+		"memories.json":                          {J: JSON{Title: "old days"}},
+		filepath.Join("todo", "shopping.json"):   {J: JSON{Title: "Groceries", Todo: &Todo{Status: StatusOpen}}},
+		filepath.Join("todo", "shipping.json"):   {J: JSON{Title: "parcel", Todo: &Todo{Status: StatusOpen}}},
+		filepath.Join("work", "meeting.json"):    {J: JSON{Title: "weekly meeting"}},
+		filepath.Join("work", "report2017.json"): {J: JSON{Title: "annual report"}},
+	}}
+	shopping := filepath.Join("todo", "shopping.json")
+	shipping := filepath.Join("todo", "shipping.json")
+	meeting := filepath.Join("work", "meeting.json")
+	isTodo := func(_ string, g *Gomem) bool { return g.IsTodo() }
+	tests := []struct {
+		query  string
+		filter func(string, *Gomem) bool
+		want   KeyMatch
+	}{
+		{query: "memo", want: KeyMatch{Key: "memo.json"}},
+		{query: "memo.json", want: KeyMatch{Key: "memo.json"}},
+		{query: shopping, want: KeyMatch{Key: shopping}},
+		// base name
+		{query: "meeting", want: KeyMatch{Key: meeting}},
+		// unique prefix of key, base name and title
+		{query: "memor", want: KeyMatch{Key: "memories.json"}},
+		{query: "shop", want: KeyMatch{Key: shopping}},
+		{query: "groc", want: KeyMatch{Key: shopping}},
+		{query: "ANNUAL", want: KeyMatch{Key: filepath.Join("work", "report2017.json")}},
+		// ambiguous
+		{query: "mem", want: KeyMatch{Candidates: []string{"memo.json", "memories.json"}}},
+		{query: "todo", want: KeyMatch{Candidates: []string{shipping, shopping}}},
+		{query: "ippin", want: KeyMatch{Candidates: []string{shipping}}},
+		// did you mean
+		{query: "shoping", want: KeyMatch{Suggestions: []string{shopping, shipping}}},
+		{query: "meting", want: KeyMatch{Suggestions: []string{meeting}}},
+		{query: "zzz", want: KeyMatch{}},
+		// filter
+		{query: "s", filter: isTodo, want: KeyMatch{Candidates: []string{shipping, shopping}}},
+		{query: "memo", filter: isTodo, want: KeyMatch{}},
+		{query: "", want: KeyMatch{}},
+	}
+	for _, v := range tests {
+		got := gs.Lookup(v.query, v.filter)
+		if !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%q: want %+v but got %+v", v.query, v.want, got)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "abc", b: "", want: 3},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "shoping", b: "shopping", want: 1},
+		{a: "日本語", b: "日本", want: 1},
+	}
+	for _, v := range tests {
+		if got := levenshtein(v.a, v.b); got != v.want {
+			t.Errorf("%q %q: want %d but got %d", v.a, v.b, v.want, got)
+		}
+	}
+}
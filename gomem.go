@@ -1,19 +1,34 @@
 package gomem
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // JSON JSON structure
+// fields other than title and content are optional
+// for read files written by old version
 type JSON struct {
-	Title   string   `json:"title"`
-	Content []string `json:"content"`
+	Version int                    `json:"version"` // set SchemaVersion by WriteFile
+	Title   string                 `json:"title"`
+	Content []string               `json:"content,omitempty"`
+	Todo    *Todo                  `json:"todo,omitempty"`
+	ID      string                 `json:"id,omitempty"`
+	Created *time.Time             `json:"created,omitempty"`
+	Updated *time.Time             `json:"updated,omitempty"`
+	Tags    []string               `json:"tags,omitempty"`
+	Meta    map[string]interface{} `json:"meta,omitempty"`
 }
 
 // Gomem have JSON structure
@@ -21,17 +36,73 @@ type Gomem struct {
 	J        JSON
 	Override bool
 	fullpath string
+	dirty    bool // modified since ReadFile or WriteFile
+	disk     *diskState
+	version  int     // schema version of file at ReadFile
+	owner    *Gomems // for update index at WriteFile
+}
+
+// diskState state of file at last ReadFile or WriteFile
+type diskState struct {
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+func newDiskState(info os.FileInfo, b []byte) *diskState {
+	return &diskState{modTime: info.ModTime(), size: info.Size(), sum: sha256.Sum256(b)}
 }
 
 // Gomems map of Gomem and data directory
 type Gomems struct {
-	Gmap map[string]*Gomem // key: filepath.Rel(Gomems.dir, Gomem.fullpath)
-	dir  string
+	Gmap     map[string]*Gomem  // key: filepath.Rel(Gomems.dir, Gomem.fullpath)
+	Broken   map[string]*Broken // unparsable files, same key as Gmap
+	dir      string
+	lock     *os.File // held lock file, nil if not held
+	readonly bool
+	index    *Index
 }
 
 // ErrFileExists exists error
 var ErrFileExists = errors.New("file exists, cannot override")
 
+// FollowSymlinks if true then IncludeJSON follows symbolic links
+// to files and directories, otherwise skip them
+var FollowSymlinks = false
+
+// ErrConflict file is modified on disk after ReadFile
+var ErrConflict = errors.New("file is modified on disk since read")
+
+// ErrReadOnly write or remove in read only session
+var ErrReadOnly = errors.New("read only session: locked by another session")
+
+// LoadError errors of IncludeJSON per file
+type LoadError struct {
+	Errs []*FileError
+}
+
+// FileError error of a file
+type FileError struct {
+	Path string // key or path
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *LoadError) add(path string, err error) {
+	e.Errs = append(e.Errs, &FileError{Path: path, Err: err})
+}
+
+func (e *LoadError) Error() string {
+	str := fmt.Sprintf("failed to load %d files:", len(e.Errs))
+	for _, x := range e.Errs {
+		str += "\n\t" + x.Error()
+	}
+	return str
+}
+
 // WritePerm if need then modify
 // This use *Gomem.WriteFile
 // Example: gomem.WritePerm = os.FileMode(0600)
@@ -47,7 +118,83 @@ func New(fpath string, override bool) (*Gomem, error) {
 	if !filepath.IsAbs(fpath) {
 		return nil, fmt.Errorf("invalid filepath: %v is not fullpath", fpath)
 	}
-	return &Gomem{fullpath: fpath, Override: override}, nil
+	now := time.Now()
+	g := &Gomem{fullpath: fpath, Override: override, dirty: true, version: SchemaVersion}
+	g.J.ID = newID()
+	g.J.Created = &now
+	return g, nil
+}
+
+// newID return random hex string
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// SetDirty mark g as modified and update g.J.Updated
+// if g.J.ID is empty, e.g. read from old file, then set new ID
+// call after modify g.J
+func (g *Gomem) SetDirty() {
+	now := time.Now()
+	g.J.Updated = &now
+	if g.J.ID == "" {
+		g.J.ID = newID()
+	}
+	g.dirty = true
+}
+
+// HasTag return true if g.J.Tags contains tag
+func (g *Gomem) HasTag(tag string) bool {
+	for _, t := range g.J.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTags append tags to g.J.Tags without duplication
+func (g *Gomem) AddTags(tags ...string) {
+	modified := false
+	for _, tag := range tags {
+		if tag == "" || g.HasTag(tag) {
+			continue
+		}
+		g.J.Tags = append(g.J.Tags, tag)
+		modified = true
+	}
+	if modified {
+		g.SetDirty()
+	}
+}
+
+// RemoveTags remove tags from g.J.Tags
+func (g *Gomem) RemoveTags(tags ...string) {
+	var kept []string
+	for _, t := range g.J.Tags {
+		remove := false
+		for _, tag := range tags {
+			if t == tag {
+				remove = true
+				break
+			}
+		}
+		if !remove {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) != len(g.J.Tags) {
+		g.J.Tags = kept
+		g.SetDirty()
+	}
+}
+
+// IsDirty return true if g has unsaved changes
+func (g *Gomem) IsDirty() bool {
+	return g.dirty
 }
 
 // IsValidFilePath if invalid then return error
@@ -67,16 +214,94 @@ func (g *Gomem) IsValidFilePath() error {
 
 // ReadFile load from g.fullpath
 func (g *Gomem) ReadFile() error {
+	info, err := os.Stat(g.fullpath)
+	if err != nil {
+		return err
+	}
 	b, err := ioutil.ReadFile(g.fullpath)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(b, &g.J)
-	return err
+	j, version, err := decodeJSON(b, g.key())
+	if err != nil {
+		return err
+	}
+	g.J = j
+	g.version = version
+	g.dirty = false
+	g.disk = newDiskState(info, b)
+	return nil
+}
+
+// IsModifiedOnDisk return true if file is changed after last ReadFile or WriteFile
+// compare mtime and size, if differ then compare content hash
+// if g is not read or written yet then existing file is modified
+func (g *Gomem) IsModifiedOnDisk() bool {
+	if g.disk == nil {
+		_, err := os.Lstat(g.fullpath)
+		return !os.IsNotExist(err)
+	}
+	info, err := os.Stat(g.fullpath)
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+	if info.ModTime().Equal(g.disk.modTime) && info.Size() == g.disk.size {
+		return false
+	}
+	b, err := ioutil.ReadFile(g.fullpath)
+	if err != nil {
+		return true
+	}
+	return sha256.Sum256(b) != g.disk.sum
+}
+
+// ReadDiskJSON return JSON of current file on disk without modify g
+func (g *Gomem) ReadDiskJSON() (JSON, error) {
+	b, err := ioutil.ReadFile(g.fullpath)
+	if err != nil {
+		return JSON{}, err
+	}
+	j, _, err := decodeJSON(b, g.key())
+	return j, err
+}
+
+// key return key of g in owner, empty if not in Gomems
+func (g *Gomem) key() string {
+	if g.owner == nil {
+		return ""
+	}
+	key, err := filepath.Rel(g.owner.dir, g.fullpath)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// Rebase adopt current file on disk as base of g
+// after Rebase, WriteFile overwrites changes on disk, for resolve ErrConflict
+func (g *Gomem) Rebase() error {
+	info, err := os.Stat(g.fullpath)
+	if os.IsNotExist(err) {
+		g.disk = nil
+		return nil
+	} else if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(g.fullpath)
+	if err != nil {
+		return err
+	}
+	g.disk = newDiskState(info, b)
+	return nil
 }
 
 // WriteFile write to g.fullpath
+// written via temporary file and rename, original file is kept when failed
+// if g is in read only Gomems then return ErrReadOnly
 func (g *Gomem) WriteFile() error {
+	if g.owner != nil && g.owner.readonly {
+		return ErrReadOnly
+	}
 	if err := g.IsValidFilePath(); err != nil {
 		return err
 	}
@@ -84,31 +309,153 @@ func (g *Gomem) WriteFile() error {
 	if _, err := os.Stat(g.fullpath); err == nil && g.Override != true {
 		return ErrFileExists
 	}
+	if g.IsModifiedOnDisk() {
+		return ErrConflict
+	}
+	g.J.Version = SchemaVersion
 	b, err := json.MarshalIndent(g.J, "", "  ")
 	if err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(g.fullpath, b, WritePerm); err != nil {
+	if err := writeFileAtomic(g.fullpath, b, WritePerm); err != nil {
+		return err
+	}
+	g.version = SchemaVersion
+	g.dirty = false
+	if info, err := os.Stat(g.fullpath); err == nil {
+		g.disk = newDiskState(info, b)
+	}
+	if g.owner != nil {
+		g.owner.reindex(g)
+	}
+	return nil
+}
+
+// writeFileAtomic write data to temporary file in same directory of fpath
+// then sync and rename to fpath
+// if failed then original fpath is not modified
+func writeFileAtomic(fpath string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(fpath)
+	var f *os.File
+	var err error
+	for i := 0; i < 10000; i++ {
+		tmpname := filepath.Join(dir, "."+base+"."+strconv.FormatInt(time.Now().UnixNano()+int64(i), 36)+".tmp")
+		f, err = os.OpenFile(tmpname, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if !os.IsExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	tmpname := f.Name()
+	if info, err := os.Stat(fpath); err == nil {
+		// keep permission of original file
+		if err := f.Chmod(info.Mode().Perm()); err != nil {
+			f.Close()
+			os.Remove(tmpname)
+			return err
+		}
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpname)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpname)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+	if err := os.Rename(tmpname, fpath); err != nil {
+		os.Remove(tmpname)
 		return err
 	}
+	// sync directory entry, ignore error because some platforms is not supported
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
 	return nil
 }
 
 // GomemsNew read from pwd return map for Gomem
+// acquire lock of dir, if locked by another session then return *LockError
+// if Tolerant and some files are failed to load then return gs with *LoadError
+// call Close for release lock
 func GomemsNew(dir string) (*Gomems, error) {
 	if !filepath.IsAbs(dir) {
 		return nil, fmt.Errorf("GomemsNew: invalid direcotry path %s", dir)
 	}
+	lock, err := acquireLock(dir)
+	if err != nil {
+		return nil, err
+	}
 	gs := &Gomems{
-		Gmap: make(map[string]*Gomem),
-		dir:  dir,
+		Gmap:   make(map[string]*Gomem),
+		Broken: make(map[string]*Broken),
+		dir:    dir,
+		lock:   lock,
 	}
 	if err := gs.IncludeJSON(); err != nil {
+		if _, ok := err.(*LoadError); ok && Tolerant {
+			gs.openIndex()
+			return gs, err
+		}
+		gs.Close()
 		return nil, err
 	}
+	gs.openIndex()
 	return gs, nil
 }
 
+// GomemsNewReadOnly read from dir without lock
+// for fallback when GomemsNew returned *LockError
+// *LoadError is same as GomemsNew
+func GomemsNewReadOnly(dir string) (*Gomems, error) {
+	if !filepath.IsAbs(dir) {
+		return nil, fmt.Errorf("GomemsNewReadOnly: invalid direcotry path %s", dir)
+	}
+	gs := &Gomems{
+		Gmap:     make(map[string]*Gomem),
+		Broken:   make(map[string]*Broken),
+		dir:      dir,
+		readonly: true,
+	}
+	if err := gs.IncludeJSON(); err != nil {
+		if _, ok := err.(*LoadError); ok && Tolerant {
+			gs.openIndex()
+			return gs, err
+		}
+		return nil, err
+	}
+	gs.openIndex()
+	return gs, nil
+}
+
+// IsReadOnly return true if gs is not held lock of gs.dir
+func (gs *Gomems) IsReadOnly() bool {
+	return gs.readonly
+}
+
+// Close write index by SaveIndex and release lock of gs.dir
+// lock is released even if failed to write index
+func (gs *Gomems) Close() error {
+	err := gs.SaveIndex()
+	if gs.lock == nil {
+		return err
+	}
+	if lerr := releaseLock(gs.lock); err == nil {
+		err = lerr
+	}
+	gs.lock = nil
+	return err
+}
+
 // AddGomem add to gs.Gmap
 func (gs *Gomems) AddGomem(g *Gomem) error {
 	key, err := filepath.Rel(gs.dir, g.fullpath)
@@ -118,10 +465,47 @@ func (gs *Gomems) AddGomem(g *Gomem) error {
 	if _, ok := gs.Gmap[key]; ok {
 		return fmt.Errorf("*Gomems.AddGomem: gs.Gmap[%s] is exists", key)
 	}
+	if _, ok := gs.Broken[key]; ok {
+		return fmt.Errorf("*Gomems.AddGomem: gs.Broken[%s] is exists, repair it", key)
+	}
+	g.owner = gs
 	gs.Gmap[key] = g
 	return nil
 }
 
+// Remove remove file of key and remove from gs.Gmap and index
+// return ErrReadOnly in read only session
+func (gs *Gomems) Remove(key string) error {
+	g, ok := gs.Gmap[key]
+	if !ok {
+		return fmt.Errorf("not found gs.Gmap[%s]", key)
+	}
+	if gs.readonly {
+		return ErrReadOnly
+	}
+	if err := os.Remove(g.fullpath); err != nil {
+		return err
+	}
+	delete(gs.Gmap, key)
+	if gs.index != nil {
+		gs.index.remove(key)
+		return gs.SaveIndex()
+	}
+	return nil
+}
+
+// Dirty return sorted keys of Gomem that have unsaved changes
+func (gs *Gomems) Dirty() []string {
+	var keys []string
+	for key, g := range gs.Gmap {
+		if g.IsDirty() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // GetAbs return filepath.Join(gs.dir+gs.Gmap[key].base)
 func (gs *Gomems) GetAbs(key string) (string, error) {
 	g, ok := gs.Gmap[key]
@@ -132,60 +516,116 @@ func (gs *Gomems) GetAbs(key string) (string, error) {
 }
 
 // IncludeJSON include from Gomems.dir
-// mapping gs.Gmap[g.base]*g
+// mapping gs.Gmap[key]*g
+// walk all subcategories, skip hidden directories
+// included Gomem is reloaded except unsaved changes, see Dirty
+// opened index is synced with reloaded files
+// if failed some files then load others and return *LoadError
+// if Tolerant then readable files failed to load are recorded to gs.Broken
 func (gs *Gomems) IncludeJSON() error {
 	if gs.Gmap == nil {
 		return fmt.Errorf("*Gomems.IncludeJSON: Gmap is nil")
 	}
+	gs.Broken = make(map[string]*Broken)
 
-	var fullpaths []string
-	var pushPaths func(string) error
-	pushPaths = func(root string) error {
-		infos, err := ioutil.ReadDir(root)
-		if err != nil {
-			return err
-		}
-		for _, info := range infos {
-			if info.IsDir() {
-				return pushPaths(filepath.Join(root, info.Name()))
-			}
-			if info.Mode().IsRegular() && strings.HasSuffix(info.Name(), ".json") {
-				if err == nil {
-					fullpaths = append(fullpaths, filepath.Join(root, info.Name()))
-				}
-			}
-		}
-		return nil
-	}
-	err := pushPaths(gs.dir)
+	lerr := &LoadError{}
+	fullpaths, err := walkJSON(gs.dir, lerr)
 	if err != nil {
 		return err
 	}
-
 	for _, x := range fullpaths {
 		key, err := filepath.Rel(gs.dir, x)
 		if err != nil {
-			// TODO: to continue?
-			return err
+			lerr.add(x, err)
+			continue
 		}
 		if g, ok := gs.Gmap[key]; ok {
+			if g.IsDirty() {
+				continue
+			}
 			if err := g.ReadFile(); err != nil {
-				return err
+				lerr.add(key, err)
 			}
 			continue
 		}
 		g, err := New(x, true)
 		if err != nil {
-			return err
+			lerr.add(key, err)
+			continue
 		}
-		gs.Gmap[key] = g
+		g.owner = gs // for key of migration
 		if err := g.ReadFile(); err != nil {
-			return err
+			if Tolerant {
+				if b, err := newBroken(x, err); err == nil {
+					gs.Broken[key] = b
+					continue
+				}
+			}
+			lerr.add(key, err)
+			continue
 		}
+		gs.Gmap[key] = g
+	}
+	if gs.index != nil {
+		gs.index.sync(gs.Gmap)
+	}
+	if len(lerr.Errs) != 0 {
+		return lerr
 	}
 	return nil
 }
 
+// walkJSON return fullpaths of "*.json" in root recursively
+// errors in subdirectories are added to lerr
+func walkJSON(root string, lerr *LoadError) ([]string, error) {
+	var fullpaths []string
+	visited := make(map[string]bool)
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		realdir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		if visited[realdir] {
+			// symlink loop
+			return nil
+		}
+		visited[realdir] = true
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			name := filepath.Join(dir, info.Name())
+			if strings.HasPrefix(info.Name(), ".") {
+				continue
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				if !FollowSymlinks {
+					continue
+				}
+				if info, err = os.Stat(name); err != nil {
+					lerr.add(name, err)
+					continue
+				}
+			}
+			switch {
+			case info.IsDir():
+				if err := walk(name); err != nil {
+					lerr.add(name, err)
+				}
+			case info.Mode().IsRegular() && strings.HasSuffix(info.Name(), ".json"):
+				fullpaths = append(fullpaths, name)
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return fullpaths, nil
+}
+
 // GetDir exported gs.dir
 func (gs *Gomems) GetDir() string {
 	return gs.dir
@@ -6,7 +6,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 )
 
 var (
@@ -20,6 +22,9 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if tmpdir, err = filepath.Abs(tmpdir); err != nil {
+		log.Fatal(err)
+	}
 	tmpfile = filepath.Join(tmpdir, "file.json")
 	if _, err = os.Create(tmpfile); err != nil {
 		log.Fatal(err)
@@ -50,13 +55,12 @@ func TestNew(t *testing.T) {
 			in:      input{path: "./test.go", flag: false},
 			wantErr: true,
 		},
-
-		// valid in
 		{
 			in:      input{path: "./foo.json", flag: false},
-			want:    "foo.json",
-			wantErr: false,
+			wantErr: true,
 		},
+
+		// valid in
 		{
 			in:      input{path: "/home/json/test/json.json"},
 			want:    "/home/json/test/json.json",
@@ -76,8 +80,8 @@ func TestNew(t *testing.T) {
 			t.Errorf("failed initalize: g == nil")
 			continue
 		}
-		if v.want != g.base {
-			t.Errorf("want: %s\nout:%s", v.want, g.base)
+		if v.want != g.fullpath {
+			t.Errorf("want: %s\nout:%s", v.want, g.fullpath)
 		}
 	}
 }
@@ -93,12 +97,12 @@ func TestGomem_IsValidFilePath(t *testing.T) {
 		wantErr bool
 	}{
 		// invalid
-		{g: &Gomem{base: ""}, wantErr: true},
-		{g: &Gomem{base: "/path/to/file.go"}, wantErr: true},
-		{g: &Gomem{base: dirname}, wantErr: true}, // dir name
+		{g: &Gomem{fullpath: ""}, wantErr: true},
+		{g: &Gomem{fullpath: "/path/to/file.go"}, wantErr: true},
+		{g: &Gomem{fullpath: dirname}, wantErr: true}, // dir name
+		{g: &Gomem{fullpath: "file.json"}, wantErr: true},
 		// valid
-		{g: &Gomem{base: "file.json"}, wantErr: false},
-		{g: &Gomem{base: filename}, wantErr: false},
+		{g: &Gomem{fullpath: filename}, wantErr: false},
 	}
 	for _, v := range tests {
 		err := v.g.IsValidFilePath()
@@ -106,7 +110,7 @@ func TestGomem_IsValidFilePath(t *testing.T) {
 			continue
 		}
 		if err != nil {
-			t.Errorf("g.base:%s, err:%v", v.g.base, err)
+			t.Errorf("g.fullpath:%s, err:%v", v.g.fullpath, err)
 			continue
 		}
 	}
@@ -145,7 +149,7 @@ func TestGomem_ReadFile(t *testing.T) {
 		if err := ioutil.WriteFile(tmpfile, v.data, 0666); err != nil {
 			t.Fatal(err)
 		}
-		g := &Gomem{base: v.fullPath}
+		g := &Gomem{fullpath: v.fullPath}
 		err := g.ReadFile()
 		if v.wantErr && err != nil {
 			continue
@@ -163,16 +167,20 @@ func TestGomem_WriteFile(t *testing.T) {
 	}{
 		{
 			in:  JSON{Title: "test", Content: []string{"test"}},
-			out: fmt.Sprintf("{\n  \"title\": \"test\",\n  \"content\": [\n    \"test\"\n  ]\n}"),
+			out: fmt.Sprintf("{\n  \"version\": %d,\n  \"title\": \"test\",\n  \"content\": [\n    \"test\"\n  ]\n}", SchemaVersion),
 		},
 	}
 
 	for _, v := range tests {
-		g := &Gomem{base: tmpfile, J: v.in, Override: true}
+		g := &Gomem{fullpath: tmpfile, J: v.in, Override: true}
+		// tmpfile exists, keep mine
+		if err := g.Rebase(); err != nil {
+			t.Fatal(err)
+		}
 		if err := g.WriteFile(); err != nil {
 			t.Fatal(err)
 		}
-		b, err := ioutil.ReadFile(g.base)
+		b, err := ioutil.ReadFile(g.fullpath)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -181,3 +189,338 @@ func TestGomem_WriteFile(t *testing.T) {
 		}
 	}
 }
+
+func TestGomem_WriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fpath := filepath.Join(dir, "memo.json")
+	orig := []byte(`{"title": "orig", "content": ["orig"]}`)
+	if err := ioutil.WriteFile(fpath, orig, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// not override: original is kept
+	g := &Gomem{fullpath: fpath, J: JSON{Title: "new"}}
+	if err := g.WriteFile(); err != ErrFileExists {
+		t.Fatalf("want ErrFileExists but got %v", err)
+	}
+	if b, err := ioutil.ReadFile(fpath); err != nil || string(b) != string(orig) {
+		t.Fatalf("original is modified: %q %v", b, err)
+	}
+
+	// override: file is not read by g
+	g.Override = true
+	if err := g.WriteFile(); err != ErrConflict {
+		t.Fatalf("want ErrConflict but got %v", err)
+	}
+	if err := g.Rebase(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.WriteFile(); err != nil {
+		t.Fatal(err)
+	}
+	g2 := &Gomem{fullpath: fpath}
+	if err := g2.ReadFile(); err != nil {
+		t.Fatal(err)
+	}
+	if g2.J.Title != "new" {
+		t.Errorf("want title %q but got %q", "new", g2.J.Title)
+	}
+	info, err := os.Stat(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("permission is not kept: %v", info.Mode().Perm())
+	}
+
+	// temporary files is not left
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		t.Errorf("temporary files is left: %q", names)
+	}
+}
+
+func TestGomems_Dirty(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "dirty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.json", "b.json"} {
+		data := []byte(`{"title": "` + name + `", "content": []}`)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gs, err := GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.Close()
+	if keys := gs.Dirty(); len(keys) != 0 {
+		t.Fatalf("want clean after load but dirty: %q", keys)
+	}
+
+	gs.Gmap["b.json"].J.Content = append(gs.Gmap["b.json"].J.Content, "modified")
+	gs.Gmap["b.json"].SetDirty()
+	g, err := New(filepath.Join(dir, "c.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.AddGomem(g); err != nil {
+		t.Fatal(err)
+	}
+	if keys := gs.Dirty(); fmt.Sprint(keys) != "[b.json c.json]" {
+		t.Fatalf("want [b.json c.json] but got %q", keys)
+	}
+
+	for _, key := range gs.Dirty() {
+		if err := gs.Gmap[key].WriteFile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if keys := gs.Dirty(); len(keys) != 0 {
+		t.Errorf("want clean after write but dirty: %q", keys)
+	}
+}
+
+func TestGomem_WriteFileConflict(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "conflict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fpath := filepath.Join(dir, "memo.json")
+	if err := ioutil.WriteFile(fpath, []byte(`{"title": "orig", "content": []}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	g := &Gomem{fullpath: fpath, Override: true}
+	if err := g.ReadFile(); err != nil {
+		t.Fatal(err)
+	}
+	if g.IsModifiedOnDisk() {
+		t.Fatal("want not modified after ReadFile")
+	}
+
+	theirs := []byte(`{"title": "theirs", "content": ["modified by another"]}`)
+	if err := ioutil.WriteFile(fpath, theirs, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if !g.IsModifiedOnDisk() {
+		t.Fatal("want modified on disk")
+	}
+	g.J.Title = "mine"
+	if err := g.WriteFile(); err != ErrConflict {
+		t.Fatalf("want ErrConflict but got %v", err)
+	}
+	if b, err := ioutil.ReadFile(fpath); err != nil || string(b) != string(theirs) {
+		t.Fatalf("file on disk is overwritten: %q %v", b, err)
+	}
+	j, err := g.ReadDiskJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Title != "theirs" || g.J.Title != "mine" {
+		t.Errorf("want disk:theirs cache:mine but got disk:%s cache:%s", j.Title, g.J.Title)
+	}
+
+	// keep mine
+	if err := g.Rebase(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.WriteFile(); err != nil {
+		t.Fatal(err)
+	}
+	if j, err := g.ReadDiskJSON(); err != nil || j.Title != "mine" {
+		t.Fatalf("want mine on disk but got %q %v", j.Title, err)
+	}
+
+	// touch without change content is not conflict
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(fpath, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if g.IsModifiedOnDisk() {
+		t.Error("want not modified when only mtime is changed")
+	}
+
+	// new memo and file created by another after load
+	newpath := filepath.Join(dir, "new.json")
+	g, err = New(newpath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.IsModifiedOnDisk() {
+		t.Fatal("want not modified without file")
+	}
+	if err := ioutil.WriteFile(newpath, theirs, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.WriteFile(); err != ErrConflict {
+		t.Fatalf("want ErrConflict but got %v", err)
+	}
+	if b, err := ioutil.ReadFile(newpath); err != nil || string(b) != string(theirs) {
+		t.Fatalf("file on disk is overwritten: %q %v", b, err)
+	}
+}
+
+func TestGomems_IncludeJSON(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"top.json":        `{"title": "top", "content": []}`,
+		"a/a.json":        `{"title": "a", "content": []}`,
+		"todo/x.json":     `{"title": "x", "content": []}`,
+		"z/deep/z.json":   `{"title": "z", "content": []}`,
+		"z/broken.json":   `{"title": `,
+		".git/hide.json":  `{"title": "hidden", "content": []}`,
+		"ignore/file.txt": `not json`,
+	}
+	for name, data := range files {
+		fpath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outside, err := ioutil.TempDir(tmpdir, "outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(outside, "o.json"), []byte(`{"title": "o"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	// same directory is not included twice
+	if err := os.Symlink(filepath.Join(dir, "a"), filepath.Join(dir, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	// loop
+	if err := os.Symlink(dir, filepath.Join(dir, "a", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		follow bool
+		want   string
+	}{
+		{follow: false, want: "[a/a.json todo/x.json top.json z/deep/z.json]"},
+		{follow: true, want: "[a/a.json link/o.json todo/x.json top.json z/deep/z.json]"},
+	}
+	defer func(b bool) { FollowSymlinks = b }(FollowSymlinks)
+	for _, v := range tests {
+		FollowSymlinks = v.follow
+		gs := &Gomems{Gmap: make(map[string]*Gomem), dir: dir}
+		err := gs.IncludeJSON()
+		lerr, ok := err.(*LoadError)
+		if !ok {
+			t.Fatalf("want *LoadError but got %v", err)
+		}
+		if len(lerr.Errs) != 1 || lerr.Errs[0].Path != filepath.Join("z", "broken.json") {
+			t.Errorf("unexpected errors: %v", lerr)
+		}
+		var keys []string
+		for key := range gs.Gmap {
+			keys = append(keys, filepath.ToSlash(key))
+		}
+		sort.Strings(keys)
+		if fmt.Sprint(keys) != v.want {
+			t.Errorf("follow:%v: want %s but got %s", v.follow, v.want, keys)
+		}
+	}
+}
+
+func TestJSON_Schema(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// old two-field file
+	fpath := filepath.Join(dir, "old.json")
+	if err := ioutil.WriteFile(fpath, []byte(`{"title": "old", "content": ["a"]}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	g := &Gomem{fullpath: fpath, Override: true}
+	if err := g.ReadFile(); err != nil {
+		t.Fatal(err)
+	}
+	if g.J.ID != "" || g.J.Created != nil || g.J.Updated != nil || g.J.Tags != nil || g.J.Meta != nil {
+		t.Errorf("unexpected fields of old file: %+v", g.J)
+	}
+	g.AddTags("work", "work", "home")
+	if fmt.Sprint(g.J.Tags) != "[work home]" {
+		t.Errorf("want [work home] but got %v", g.J.Tags)
+	}
+	if g.J.ID == "" || g.J.Updated == nil || !g.IsDirty() {
+		t.Errorf("id and updated is not maintained: %+v", g.J)
+	}
+	g.RemoveTags("work")
+	g.J.Meta = map[string]interface{}{"source": "test"}
+	if err := g.WriteFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	g2 := &Gomem{fullpath: fpath}
+	if err := g2.ReadFile(); err != nil {
+		t.Fatal(err)
+	}
+	if g2.J.ID != g.J.ID || !g2.J.Updated.Equal(*g.J.Updated) ||
+		fmt.Sprint(g2.J.Tags) != "[home]" || g2.J.Meta["source"] != "test" {
+		t.Errorf("not round trip:\n\twrote:%+v\n\tread:%+v", g.J, g2.J)
+	}
+
+	g3, err := New(filepath.Join(dir, "new.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g3.J.ID == "" || g3.J.ID == g.J.ID || g3.J.Created == nil {
+		t.Errorf("want new id and created: %+v", g3.J)
+	}
+}
+
+func TestGomems_IncludeJSONDirty(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "includedirty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.json", "b.json"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(`{"title": "disk"}`), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gs := &Gomems{Gmap: make(map[string]*Gomem), dir: dir}
+	if err := gs.IncludeJSON(); err != nil {
+		t.Fatal(err)
+	}
+	gs.Gmap["a.json"].J.Title = "mine"
+	gs.Gmap["a.json"].SetDirty()
+	for _, name := range []string{"a.json", "b.json"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(`{"title": "changed"}`), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gs.IncludeJSON(); err != nil {
+		t.Fatal(err)
+	}
+	// unsaved changes are kept, others are reloaded
+	if a := gs.Gmap["a.json"]; a.J.Title != "mine" || !a.IsDirty() {
+		t.Errorf("unsaved changes are lost: %q dirty:%v", a.J.Title, a.IsDirty())
+	}
+	if b := gs.Gmap["b.json"]; b.J.Title != "changed" {
+		t.Errorf("want reloaded but got %q", b.J.Title)
+	}
+}
@@ -0,0 +1,160 @@
+package gomem
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultHistorySize default cap of History
+const DefaultHistorySize = 1000
+
+// History command history of Repl
+// lines are appended to file, file is compacted when twice of Max
+type History struct {
+	Lines []string // oldest first
+	Max   int      // cap of Lines, 0 for DefaultHistorySize
+	path  string   // empty for memory only
+	count int      // number of lines in file
+}
+
+// LoadHistory read last max lines from path, path is created by Add if not exists
+// empty path is history in memory only
+func LoadHistory(path string, max int) (*History, error) {
+	if max <= 0 {
+		max = DefaultHistorySize
+	}
+	h := &History{Max: max, path: path}
+	if path == "" {
+		return h, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if sc.Text() == "" {
+			continue
+		}
+		h.Lines = append(h.Lines, sc.Text())
+		h.count++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	h.trim()
+	return h, nil
+}
+
+// DefaultHistoryPath return gomem/history in user config directory
+func DefaultHistoryPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gomem", "history"), nil
+}
+
+func (h *History) max() int {
+	if h.Max <= 0 {
+		return DefaultHistorySize
+	}
+	return h.Max
+}
+
+func (h *History) trim() {
+	if over := len(h.Lines) - h.max(); over > 0 {
+		h.Lines = append([]string(nil), h.Lines[over:]...)
+	}
+}
+
+// Add append line, empty line and same as last line are ignored
+func (h *History) Add(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.ContainsAny(line, "\r\n") ||
+		(len(h.Lines) != 0 && h.Lines[len(h.Lines)-1] == line) {
+		return nil
+	}
+	h.Lines = append(h.Lines, line)
+	h.trim()
+	if h.path == "" {
+		return nil
+	}
+	if h.count >= 2*h.max() {
+		return h.compact()
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	h.count++
+	return f.Close()
+}
+
+// compact rewrite file by h.Lines
+func (h *History) compact() error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	data := strings.Join(h.Lines, "\n") + "\n"
+	if err := writeFileAtomic(h.path, []byte(data), 0600); err != nil {
+		return err
+	}
+	h.count = len(h.Lines)
+	return nil
+}
+
+// Expand replace "!!" by last line and "!n" by nth line from 1
+// return true if expanded, other lines are returned as is
+func (h *History) Expand(line string) (string, bool, error) {
+	if !strings.HasPrefix(line, "!") {
+		return line, false, nil
+	}
+	if line == "!!" {
+		if len(h.Lines) == 0 {
+			return "", false, fmt.Errorf("history is empty")
+		}
+		return h.Lines[len(h.Lines)-1], true, nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return line, false, nil
+	}
+	if n < 1 || n > len(h.Lines) {
+		return "", false, fmt.Errorf("invalid history number: %s", line)
+	}
+	return h.Lines[n-1], true, nil
+}
+
+// String return numbered lines for history command
+func (h *History) String() string {
+	return h.last(len(h.Lines))
+}
+
+// last return numbered last n lines
+func (h *History) last(n int) string {
+	start := len(h.Lines) - n
+	if start < 0 {
+		start = 0
+	}
+	var str string
+	for i := start; i < len(h.Lines); i++ {
+		str += fmt.Sprintf("%5d  %s\n", i+1, h.Lines[i])
+	}
+	return str
+}
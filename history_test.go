@@ -0,0 +1,96 @@
+package gomem
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "sub", "history")
+	h, err := LoadHistory(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"ls", "ls", " ", "show a", "todo", "la"} {
+		if err := h.Add(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"show a", "todo", "la"}
+	if !reflect.DeepEqual(h.Lines, want) {
+		t.Errorf("want %q but got %q", want, h.Lines)
+	}
+
+	// reload from file
+	h, err = LoadHistory(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h.Lines, want) {
+		t.Errorf("reload: want %q but got %q", want, h.Lines)
+	}
+
+	// file is compacted
+	for _, line := range []string{"a", "b", "c", "d", "e"} {
+		if err := h.Add(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\n"); n > 6 {
+		t.Errorf("file is not compacted: %d lines", n)
+	}
+	h, err = LoadHistory(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c", "d", "e"}; !reflect.DeepEqual(h.Lines, want) {
+		t.Errorf("want %q but got %q", want, h.Lines)
+	}
+}
+
+func TestHistory_Expand(t *testing.T) {
+	h := &History{Lines: []string{"ls", "show a"}}
+	tests := []struct {
+		in       string
+		want     string
+		expanded bool
+		wantErr  bool
+	}{
+		{in: "!1", want: "ls", expanded: true},
+		{in: "!2", want: "show a", expanded: true},
+		{in: "!!", want: "show a", expanded: true},
+		{in: "!3", wantErr: true},
+		{in: "!0", wantErr: true},
+		{in: "!x", want: "!x"},
+		{in: "la", want: "la"},
+	}
+	for _, v := range tests {
+		got, expanded, err := h.Expand(v.in)
+		if v.wantErr {
+			if err == nil {
+				t.Errorf("%q: want error", v.in)
+			}
+			continue
+		}
+		if err != nil || got != v.want || expanded != v.expanded {
+			t.Errorf("%q: want %q %v but got %q %v %v", v.in, v.want, v.expanded, got, expanded, err)
+		}
+	}
+	if _, _, err := (&History{}).Expand("!!"); err == nil {
+		t.Error("want error for empty history")
+	}
+	if got, want := h.String(), "    1  ls\n    2  show a\n"; got != want {
+		t.Errorf("want %q but got %q", want, got)
+	}
+}
@@ -0,0 +1,273 @@
+package gomem
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// IndexDir hidden directory in Gomems.dir for search index
+const IndexDir = ".gomem"
+
+// indexFile name of index file in IndexDir
+const indexFile = "index.json"
+
+// indexVersion if changed then index is rebuilt
+const indexVersion = 1
+
+// Index inverted index of Gomems for ranked search
+type Index struct {
+	Version  int                       `json:"version"`
+	Docs     map[string]*indexDoc      `json:"docs"`     // key: Gomems key
+	Postings map[string]map[string]int `json:"postings"` // term: key: term frequency
+	path     string                    // empty if not saved
+	dirty    bool                      // modified since load or save
+}
+
+// indexDoc indexed state of file
+type indexDoc struct {
+	ModTime time.Time `json:"mtime"` // zero if indexed from unsaved cache
+	Size    int64     `json:"size"`
+	Length  int       `json:"length"` // number of terms
+	Terms   []string  `json:"terms"`
+}
+
+func newIndex(path string) *Index {
+	return &Index{
+		Version:  indexVersion,
+		Docs:     make(map[string]*indexDoc),
+		Postings: make(map[string]map[string]int),
+		path:     path,
+	}
+}
+
+// loadIndex read index from path, if not exists or invalid then return empty index
+func loadIndex(path string) *Index {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newIndex(path)
+	}
+	idx := newIndex(path)
+	if err := json.Unmarshal(b, idx); err != nil || idx.Version != indexVersion ||
+		idx.Docs == nil || idx.Postings == nil {
+		return newIndex(path)
+	}
+	idx.path = path
+	return idx
+}
+
+// save write index to idx.path if modified
+func (idx *Index) save() error {
+	if idx.path == "" || !idx.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0777); err != nil {
+		return err
+	}
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(idx.path, b, WritePerm); err != nil {
+		return err
+	}
+	idx.dirty = false
+	return nil
+}
+
+// tokenize split s to lower case terms of letters and digits
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// termsOf return terms of title, lines and tags of g
+func termsOf(g *Gomem) []string {
+	terms := tokenize(g.J.Title)
+	for _, line := range g.J.Lines() {
+		terms = append(terms, tokenize(line)...)
+	}
+	for _, tag := range g.J.Tags {
+		terms = append(terms, tokenize(tag)...)
+	}
+	return terms
+}
+
+// isFresh return true if doc of key is indexed from current file of g
+func (idx *Index) isFresh(key string, g *Gomem) bool {
+	doc, ok := idx.Docs[key]
+	return ok && g.disk != nil && !g.dirty && !doc.ModTime.IsZero() &&
+		doc.ModTime.Equal(g.disk.modTime) && doc.Size == g.disk.size
+}
+
+// update index g as key
+func (idx *Index) update(key string, g *Gomem) {
+	idx.remove(key)
+	terms := termsOf(g)
+	doc := &indexDoc{Length: len(terms)}
+	if g.disk != nil && !g.dirty {
+		doc.ModTime, doc.Size = g.disk.modTime, g.disk.size
+	}
+	tf := make(map[string]int)
+	for _, t := range terms {
+		tf[t]++
+	}
+	for t, n := range tf {
+		if idx.Postings[t] == nil {
+			idx.Postings[t] = make(map[string]int)
+		}
+		idx.Postings[t][key] = n
+		doc.Terms = append(doc.Terms, t)
+	}
+	sort.Strings(doc.Terms)
+	idx.Docs[key] = doc
+	idx.dirty = true
+}
+
+// remove key from index
+func (idx *Index) remove(key string) {
+	doc, ok := idx.Docs[key]
+	if !ok {
+		return
+	}
+	for _, t := range doc.Terms {
+		delete(idx.Postings[t], key)
+		if len(idx.Postings[t]) == 0 {
+			delete(idx.Postings, t)
+		}
+	}
+	delete(idx.Docs, key)
+	idx.dirty = true
+}
+
+// sync update index for gs.Gmap, return true if modified
+func (idx *Index) sync(gmap map[string]*Gomem) bool {
+	modified := false
+	for key := range idx.Docs {
+		if _, ok := gmap[key]; !ok {
+			idx.remove(key)
+			modified = true
+		}
+	}
+	for key, g := range gmap {
+		if !idx.isFresh(key, g) {
+			idx.update(key, g)
+			modified = true
+		}
+	}
+	return modified
+}
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// score return BM25 scores of keys that contain any of terms
+func (idx *Index) score(terms []string) map[string]float64 {
+	n := float64(len(idx.Docs))
+	if n == 0 {
+		return nil
+	}
+	var total int
+	for _, doc := range idx.Docs {
+		total += doc.Length
+	}
+	avgdl := float64(total) / n
+	if avgdl == 0 {
+		avgdl = 1
+	}
+	scores := make(map[string]float64)
+	for _, t := range terms {
+		postings := idx.Postings[t]
+		df := float64(len(postings))
+		if df == 0 {
+			continue
+		}
+		idf := math.Log((n-df+0.5)/(df+0.5) + 1)
+		for key, tf := range postings {
+			f := float64(tf)
+			dl := float64(idx.Docs[key].Length)
+			scores[key] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*dl/avgdl))
+		}
+	}
+	return scores
+}
+
+// openIndex load index of gs.dir and sync with gs.Gmap
+// synced index is written by SaveIndex, unwritable index does not block open
+func (gs *Gomems) openIndex() {
+	gs.index = loadIndex(filepath.Join(gs.dir, IndexDir, indexFile))
+	gs.index.sync(gs.Gmap)
+}
+
+// SaveIndex write index if modified since load or last save
+// read only session does not write
+func (gs *Gomems) SaveIndex() error {
+	if gs.readonly || gs.index == nil {
+		return nil
+	}
+	return gs.index.save()
+}
+
+// getIndex return gs.index, if nil then build in memory
+func (gs *Gomems) getIndex() *Index {
+	if gs.index == nil {
+		gs.index = newIndex("")
+		gs.index.sync(gs.Gmap)
+	}
+	return gs.index
+}
+
+// reindex update index of g after WriteFile, written by SaveIndex
+func (gs *Gomems) reindex(g *Gomem) {
+	if key := g.key(); gs.index != nil && key != "" {
+		gs.index.update(key, g)
+	}
+}
+
+// searchRanked search by index, sorted by BM25 score
+// unsaved cache is indexed in memory before search
+func (gs *Gomems) searchRanked(query string) ([]*SearchResult, error) {
+	terms := tokenize(query)
+	patterns, err := compileQuery(strings.Join(terms, " "), SearchAll)
+	if err != nil {
+		return nil, err
+	}
+	idx := gs.getIndex()
+	for _, key := range gs.Dirty() {
+		idx.update(key, gs.Gmap[key])
+	}
+	scores := idx.score(terms)
+	var results []*SearchResult
+	for key, score := range scores {
+		g, ok := gs.Gmap[key]
+		if !ok {
+			continue
+		}
+		r := &SearchResult{Key: key, Title: g.J.Title, Score: score}
+		matched := make([]bool, len(patterns))
+		r.TitleSpans = findSpans(g.J.Title, patterns, matched)
+		for i, line := range g.J.Lines() {
+			if spans := findSpans(line, patterns, matched); spans != nil {
+				r.Lines = append(r.Lines, LineMatch{Index: i, Text: line, Spans: spans})
+			}
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Key < results[j].Key
+	})
+	return results, nil
+}
@@ -0,0 +1,242 @@
+package gomem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{in: "Hello, World!", want: []string{"hello", "world"}},
+		{in: "buy 2 eggs/milk", want: []string{"buy", "2", "eggs", "milk"}},
+		{in: "  ", want: []string{}},
+	}
+	for _, v := range tests {
+		got := tokenize(v.in)
+		if len(got) == 0 && len(v.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%q: want %v but got %v", v.in, v.want, got)
+		}
+	}
+}
+
+func TestGomems_SearchRanked(t *testing.T) {
+	gs := &Gomems{Gmap: map[string]*Gomem{
+		"a.json": {J: JSON{Title: "eggs", Content: []string{"eggs eggs", "milk"}}},
+		"b.json": {J: JSON{Title: "meeting", Content: []string{"bring eggs", "agenda budget", "room"}}},
+		"c.json": {J: JSON{Title: "notes", Content: []string{"nothing"}, Tags: []string{"milk"}}},
+	}}
+	results, err := gs.Search("eggs milk", SearchRanked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, r := range results {
+		keys = append(keys, r.Key)
+		if r.Score <= 0 {
+			t.Errorf("%s: want positive score but got %v", r.Key, r.Score)
+		}
+	}
+	if want := []string{"a.json", "c.json", "b.json"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("want %v but got %v", want, keys)
+	}
+	if len(results) > 0 && len(results[0].Lines) != 2 {
+		t.Errorf("want 2 matched lines but got %+v", results[0].Lines)
+	}
+
+	// unsaved change is searched
+	gs.Gmap["c.json"].J.Content = []string{"budget"}
+	gs.Gmap["c.json"].SetDirty()
+	results, err = gs.Search("budget", SearchRanked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("want 2 results but got %d", len(results))
+	}
+
+	if _, err := gs.Search("!!", SearchRanked); err == nil {
+		t.Error("want error for empty query")
+	}
+}
+
+func TestGomemsNew_Index(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, title string) {
+		g, err := New(filepath.Join(dir, name), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.J.Title = title
+		if err := g.WriteFile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.json", "apple")
+	write("b.json", "banana")
+	found := func(gs *Gomems, query string) []string {
+		results, err := gs.Search(query, SearchRanked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, r := range results {
+			keys = append(keys, r.Key)
+		}
+		return keys
+	}
+
+	gs, err := GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, IndexDir, indexFile)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("index is written before SaveIndex: %v", err)
+	}
+	if err := gs.SaveIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("index is not created: %v", err)
+	}
+	if got := found(gs, "apple"); !reflect.DeepEqual(got, []string{"a.json"}) {
+		t.Errorf("want [a.json] but got %v", got)
+	}
+
+	// WriteFile and Remove update index
+	g := gs.Gmap["a.json"]
+	g.J.Title = "cherry"
+	g.SetDirty()
+	if err := g.WriteFile(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loadIndex(path).Postings["cherry"]; ok {
+		t.Error("index is written by each WriteFile")
+	}
+	if err := gs.Remove("b.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	idx := loadIndex(path)
+	if _, ok := idx.Postings["cherry"]["a.json"]; !ok {
+		t.Errorf("index is not updated by WriteFile: %+v", idx.Postings)
+	}
+	if _, ok := idx.Docs["b.json"]; ok {
+		t.Error("index is not updated by Remove")
+	}
+
+	// modified by other process
+	time.Sleep(10 * time.Millisecond)
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"version":2,"title":"durian"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	write("c.json", "elderberry")
+	gs, err = GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.Close()
+	if got := found(gs, "durian elderberry cherry"); !reflect.DeepEqual(got, []string{"a.json", "c.json"}) {
+		t.Errorf("want [a.json c.json] but got %v", got)
+	}
+	if err := gs.SaveIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read only session loads saved index
+	ro, err := GomemsNewReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ro.index.dirty || len(ro.index.Docs) != 2 {
+		t.Errorf("index is rebuilt in read only session: dirty:%v docs:%d", ro.index.dirty, len(ro.index.Docs))
+	}
+
+	// broken index is rebuilt
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ro, err = GomemsNewReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := found(ro, "durian"); !reflect.DeepEqual(got, []string{"a.json"}) {
+		t.Errorf("want [a.json] but got %v", got)
+	}
+}
+
+func TestGomemsNew_UnwritableIndex(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "unwritableindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"title":"apple"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// IndexDir is not directory
+	if err := ioutil.WriteFile(filepath.Join(dir, IndexDir), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	gs, err := GomemsNew(dir)
+	if err != nil {
+		t.Fatalf("unwritable index blocks open: %v", err)
+	}
+	if results, err := gs.Search("apple", SearchRanked); err != nil || len(results) != 1 {
+		t.Errorf("want in memory index but got %v %v", results, err)
+	}
+	if err := gs.SaveIndex(); err == nil {
+		t.Error("want error of SaveIndex")
+	}
+	if err := gs.Close(); err == nil {
+		t.Error("want error of Close")
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFileName)); !os.IsNotExist(err) {
+		t.Errorf("lock is not released: %v", err)
+	}
+}
+
+func TestGomems_IncludeJSONIndex(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "includeindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fpath := filepath.Join(dir, "a.json")
+	if err := ioutil.WriteFile(fpath, []byte(`{"title": "apple"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	gs, err := GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.Close()
+	if err := ioutil.WriteFile(fpath, []byte(`{"title": "banana split"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.IncludeJSON(); err != nil {
+		t.Fatal(err)
+	}
+	for query, want := range map[string]int{"banana": 1, "apple": 0} {
+		results, err := gs.Search(query, SearchRanked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != want {
+			t.Errorf("%s: want %d results but got %d", query, want, len(results))
+		}
+	}
+}
@@ -0,0 +1,612 @@
+// Package lineedit line editor of terminal for gomem.SubCommands.LineReader
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/kamisari/gomem"
+)
+
+// ErrNotTerminal returned by NewLineEditor when input is not terminal
+var ErrNotTerminal = errors.New("not a terminal")
+
+// Keymaps accepted by NewLineEditor
+var Keymaps = []string{"emacs", "vi"}
+
+// LineEditor line editor for terminal
+// emacs keymap: C-a C-e C-b C-f C-d C-h C-k C-u C-w C-y C-t C-l C-p C-n M-b M-f M-d
+// vi keymap: insert mode and normal mode by ESC,
+// h l 0 ^ $ w b e x X D C dd cc s r i a I A p P k j
+// arrows, Home, End and Delete in both keymaps, Tab for Complete
+type LineEditor struct {
+	Complete func(head string) []string // return candidates of whole text before cursor
+	History  *gomem.History             // for up and down, nil for no history
+
+	in     *bufio.Reader
+	out    io.Writer
+	fd     uintptr // terminal fd for raw mode
+	vi     bool
+	buf    []rune
+	pos    int
+	yank   []rune
+	prompt string
+	width  int // columns of terminal, 0 if unknown
+	row    int // row of cursor from first row of prompt
+}
+
+// NewLineEditor return editor of in, if in is not terminal then return ErrNotTerminal
+// keymap is "emacs" or "vi"
+func NewLineEditor(in *os.File, out io.Writer, keymap string) (*LineEditor, error) {
+	le, err := newLineEditor(in, out, keymap)
+	if err != nil {
+		return nil, err
+	}
+	if !isTerminal(in.Fd()) {
+		return nil, ErrNotTerminal
+	}
+	le.fd = in.Fd()
+	return le, nil
+}
+
+func newLineEditor(in io.Reader, out io.Writer, keymap string) (*LineEditor, error) {
+	le := &LineEditor{in: bufio.NewReader(in), out: out}
+	switch keymap {
+	case "", "emacs":
+	case "vi":
+		le.vi = true
+	default:
+		return nil, fmt.Errorf("invalid keymap %q: require %s", keymap, strings.Join(Keymaps, " or "))
+	}
+	return le, nil
+}
+
+// ReadLine read a line with prompt in raw mode
+// lines of prompt before last line are printed as is
+// return gomem.ErrInterrupt by C-c and io.EOF by C-d on empty line
+func (le *LineEditor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(le.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	le.width = termWidth(le.fd)
+	return le.readLine(prompt)
+}
+
+// key codes
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlT     = 20
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyCtrlY     = 25
+	keyEsc       = 27
+	keyBackspace = 127
+
+	// decoded escape sequences, out of unicode range
+	keyUp = unicode.MaxRune + 1 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyAltB
+	keyAltF
+	keyAltD
+	keyUnknown
+)
+
+// readKey read a key, decode escape sequences
+// lone ESC is returned if no more input is buffered
+func (le *LineEditor) readKey() (rune, error) {
+	r, _, err := le.in.ReadRune()
+	if err != nil || r != keyEsc || le.in.Buffered() == 0 {
+		return r, err
+	}
+	r, _, err = le.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch r {
+	case 'b':
+		return keyAltB, nil
+	case 'f':
+		return keyAltF, nil
+	case 'd':
+		return keyAltD, nil
+	case '[', 'O':
+	default:
+		return keyUnknown, nil
+	}
+	var seq []rune
+	for {
+		c, _, err := le.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return keyUp, nil
+	case "B":
+		return keyDown, nil
+	case "C":
+		return keyRight, nil
+	case "D":
+		return keyLeft, nil
+	case "H", "1~", "7~":
+		return keyHome, nil
+	case "F", "4~", "8~":
+		return keyEnd, nil
+	case "3~":
+		return keyDelete, nil
+	}
+	return keyUnknown, nil
+}
+
+// readLine edit line until Enter
+func (le *LineEditor) readLine(prompt string) (string, error) {
+	if i := strings.LastIndex(prompt, "\n"); i != -1 {
+		fmt.Fprint(le.out, prompt[:i+1])
+		prompt = prompt[i+1:]
+	}
+	le.prompt = prompt
+	le.buf, le.pos, le.row = nil, 0, 0
+	insert := true // vi insert mode
+	hist := len(le.history())
+	saved := []rune(nil) // editing line while browsing history
+	lastTab := false
+	le.refresh()
+	for {
+		key, err := le.readKey()
+		if err != nil {
+			if err == io.EOF && len(le.buf) != 0 {
+				break
+			}
+			return "", err
+		}
+		tab := false
+		switch {
+		case key == keyCR || key == keyLF:
+			le.finish("\n")
+			return string(le.buf), nil
+		case key == keyCtrlC:
+			le.finish("^C\n")
+			return "", gomem.ErrInterrupt
+		case key == keyCtrlD && len(le.buf) == 0:
+			le.finish("\n")
+			return "", io.EOF
+		case key == keyUp || key == keyCtrlP || key == keyDown || key == keyCtrlN:
+			up := key == keyUp || key == keyCtrlP
+			hist, saved = le.browse(hist, saved, up)
+		case key == keyTab:
+			le.complete(lastTab)
+			tab = true
+		case le.vi && !insert:
+			insert = le.viNormal(key, &hist, &saved)
+		case le.vi && key == keyEsc:
+			insert = false
+			le.move(-1)
+		default:
+			le.edit(key)
+		}
+		lastTab = tab
+		le.refresh()
+	}
+	le.finish("\n")
+	return string(le.buf), nil
+}
+
+// finish move cursor to end of line and print s that ends with newline
+func (le *LineEditor) finish(s string) {
+	le.pos = len(le.buf)
+	le.refresh()
+	fmt.Fprint(le.out, s)
+	le.row = 0
+}
+
+// history return lines of le.History
+// line is recorded by caller, e.g. Repl
+func (le *LineEditor) history() []string {
+	if le.History == nil {
+		return nil
+	}
+	return le.History.Lines
+}
+
+// browse move to previous or next line of History
+func (le *LineEditor) browse(hist int, saved []rune, up bool) (int, []rune) {
+	lines := le.history()
+	if hist == len(lines) {
+		saved = append([]rune(nil), le.buf...)
+	}
+	switch {
+	case up && hist > 0:
+		hist--
+	case !up && hist < len(lines):
+		hist++
+	default:
+		return hist, saved
+	}
+	if hist == len(lines) {
+		le.buf = append([]rune(nil), saved...)
+	} else {
+		le.buf = []rune(lines[hist])
+	}
+	le.pos = len(le.buf)
+	return hist, saved
+}
+
+// edit handle emacs key or vi insert mode key
+func (le *LineEditor) edit(key rune) {
+	switch key {
+	case keyCtrlA, keyHome:
+		le.pos = 0
+	case keyCtrlE, keyEnd:
+		le.pos = len(le.buf)
+	case keyCtrlB, keyLeft:
+		le.move(-1)
+	case keyCtrlF, keyRight:
+		le.move(1)
+	case keyCtrlD, keyDelete:
+		le.delete(le.pos, le.pos+1)
+	case keyCtrlH, keyBackspace:
+		if le.pos > 0 {
+			le.delete(le.pos-1, le.pos)
+		}
+	case keyCtrlK:
+		le.kill(le.pos, len(le.buf))
+	case keyCtrlU:
+		le.kill(0, le.pos)
+	case keyCtrlW:
+		le.kill(le.wordBack(), le.pos)
+	case keyCtrlY:
+		le.insert(le.yank...)
+	case keyCtrlT:
+		if le.pos > 0 && len(le.buf) > 1 {
+			if le.pos == len(le.buf) {
+				le.pos--
+			}
+			le.buf[le.pos-1], le.buf[le.pos] = le.buf[le.pos], le.buf[le.pos-1]
+			le.pos++
+		}
+	case keyCtrlL:
+		fmt.Fprint(le.out, "\x1b[H\x1b[2J")
+		le.row = 0
+	case keyAltB:
+		le.pos = le.wordBack()
+	case keyAltF:
+		le.pos = le.wordForward()
+	case keyAltD:
+		le.kill(le.pos, le.wordForward())
+	default:
+		if unicode.IsPrint(key) {
+			le.insert(key)
+		}
+	}
+}
+
+// viNormal handle vi normal mode key, return true if insert mode
+func (le *LineEditor) viNormal(key rune, hist *int, saved *[]rune) bool {
+	switch key {
+	case 'h', keyLeft, keyBackspace, keyCtrlH:
+		le.move(-1)
+	case 'l', keyRight, ' ':
+		le.move(1)
+	case '0', '^', keyHome:
+		le.pos = 0
+	case '$', keyEnd:
+		le.pos = len(le.buf)
+		le.move(-1)
+	case 'w':
+		le.pos = le.wordStart()
+	case 'b':
+		le.pos = le.wordBack()
+	case 'e':
+		le.pos = le.wordEnd()
+	case 'x', keyDelete:
+		le.kill(le.pos, le.pos+1)
+		le.clampNormal()
+	case 'X':
+		if le.pos > 0 {
+			le.kill(le.pos-1, le.pos)
+		}
+	case 'D':
+		le.kill(le.pos, len(le.buf))
+		le.clampNormal()
+	case 'C':
+		le.kill(le.pos, len(le.buf))
+		return true
+	case 's':
+		le.kill(le.pos, le.pos+1)
+		return true
+	case 'd', 'c':
+		next, err := le.readKey()
+		if err != nil || next != key {
+			return false
+		}
+		le.kill(0, len(le.buf))
+		return key == 'c'
+	case 'r':
+		next, err := le.readKey()
+		if err == nil && unicode.IsPrint(next) && le.pos < len(le.buf) {
+			le.buf[le.pos] = next
+		}
+	case 'p':
+		le.move(1)
+		le.insert(le.yank...)
+		le.move(-1)
+	case 'P':
+		le.insert(le.yank...)
+		le.move(-1)
+	case 'i':
+		return true
+	case 'a':
+		le.move(1)
+		return true
+	case 'I':
+		le.pos = 0
+		return true
+	case 'A':
+		le.pos = len(le.buf)
+		return true
+	case 'k', 'j':
+		*hist, *saved = le.browse(*hist, *saved, key == 'k')
+		le.pos = 0
+	}
+	return false
+}
+
+// clampNormal cursor is on last rune in vi normal mode
+func (le *LineEditor) clampNormal() {
+	if le.pos >= len(le.buf) && le.pos > 0 {
+		le.pos = len(le.buf) - 1
+	}
+}
+
+func (le *LineEditor) move(n int) {
+	le.pos += n
+	if le.pos < 0 {
+		le.pos = 0
+	}
+	if le.pos > len(le.buf) {
+		le.pos = len(le.buf)
+	}
+}
+
+func (le *LineEditor) insert(rs ...rune) {
+	buf := make([]rune, 0, len(le.buf)+len(rs))
+	buf = append(buf, le.buf[:le.pos]...)
+	buf = append(buf, rs...)
+	le.buf = append(buf, le.buf[le.pos:]...)
+	le.pos += len(rs)
+}
+
+// delete runes in [from, to)
+func (le *LineEditor) delete(from, to int) {
+	if to > len(le.buf) {
+		to = len(le.buf)
+	}
+	if from >= to {
+		return
+	}
+	le.buf = append(le.buf[:from], le.buf[to:]...)
+	le.pos = from
+}
+
+// kill delete and save to yank buffer
+func (le *LineEditor) kill(from, to int) {
+	if to > len(le.buf) {
+		to = len(le.buf)
+	}
+	if from >= to {
+		return
+	}
+	le.yank = append([]rune(nil), le.buf[from:to]...)
+	le.delete(from, to)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+}
+
+// wordBack return start of word before cursor
+func (le *LineEditor) wordBack() int {
+	i := le.pos
+	for i > 0 && !isWordRune(le.buf[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(le.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordForward return end of word after cursor
+func (le *LineEditor) wordForward() int {
+	i := le.pos
+	for i < len(le.buf) && !isWordRune(le.buf[i]) {
+		i++
+	}
+	for i < len(le.buf) && isWordRune(le.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// wordStart return start of next word
+func (le *LineEditor) wordStart() int {
+	i := le.pos
+	for i < len(le.buf) && isWordRune(le.buf[i]) {
+		i++
+	}
+	for i < len(le.buf) && !isWordRune(le.buf[i]) {
+		i++
+	}
+	if i == len(le.buf) && i > 0 {
+		i--
+	}
+	return i
+}
+
+// wordEnd return last rune of word
+func (le *LineEditor) wordEnd() int {
+	i := le.pos + 1
+	for i < len(le.buf) && !isWordRune(le.buf[i]) {
+		i++
+	}
+	for i+1 < len(le.buf) && isWordRune(le.buf[i+1]) {
+		i++
+	}
+	if i >= len(le.buf) {
+		i = len(le.buf) - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	return i
+}
+
+// complete replace text before cursor by candidate or common prefix of candidates
+// if not progressed by second Tab then show candidates
+func (le *LineEditor) complete(list bool) {
+	if le.Complete == nil {
+		return
+	}
+	head := string(le.buf[:le.pos])
+	candidates := le.Complete(head)
+	if len(candidates) == 0 {
+		fmt.Fprint(le.out, "\a")
+		return
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			_, size := lastRune(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	if prefix != head && (len(candidates) == 1 || strings.HasPrefix(prefix, head)) {
+		rest := append([]rune(nil), le.buf[le.pos:]...)
+		le.buf = append([]rune(prefix), rest...)
+		le.pos = len([]rune(prefix))
+		return
+	}
+	if list && len(candidates) > 1 {
+		pos := le.pos
+		le.finish("\n" + strings.Join(candidates, "  ") + "\n")
+		le.pos = pos
+	}
+}
+
+func lastRune(s string) (rune, int) {
+	rs := []rune(s)
+	if len(rs) == 0 {
+		return 0, 0
+	}
+	r := rs[len(rs)-1]
+	return r, len(string(r))
+}
+
+// refresh redraw prompt and line from first row, move cursor to pos
+// position is by display width, line longer than le.width is wrapped
+func (le *LineEditor) refresh() {
+	var b strings.Builder
+	if le.row > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", le.row)
+	}
+	b.WriteString("\r" + le.prompt + string(le.buf) + "\x1b[J")
+	head := displayWidth(le.prompt)
+	end := head + displayWidth(string(le.buf))
+	cur := head + displayWidth(string(le.buf[:le.pos]))
+	endRow, row, col := 0, 0, cur
+	if le.width > 0 {
+		endRow, row, col = end/le.width, cur/le.width, cur%le.width
+		if end > 0 && end%le.width == 0 {
+			// cursor stays on last column of full row
+			b.WriteString("\r\n")
+		}
+	}
+	if endRow > row {
+		fmt.Fprintf(&b, "\x1b[%dA", endRow-row)
+	}
+	b.WriteString("\r")
+	if col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	le.row = row
+	fmt.Fprint(le.out, b.String())
+}
+
+// wideRunes ranges of east asian wide and fullwidth runes
+var wideRunes = [][2]rune{
+	{0x1100, 0x115f}, {0x2e80, 0x303e}, {0x3041, 0x33ff}, {0x3400, 0x4dbf},
+	{0x4e00, 0x9fff}, {0xa000, 0xa4cf}, {0xa960, 0xa97f}, {0xac00, 0xd7a3},
+	{0xf900, 0xfaff}, {0xfe10, 0xfe19}, {0xfe30, 0xfe6f}, {0xff00, 0xff60},
+	{0xffe0, 0xffe6}, {0x1f300, 0x1f64f}, {0x1f900, 0x1f9ff}, {0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+// runeWidth return columns of r in terminal
+func runeWidth(r rune) int {
+	if r < 0x20 || r >= 0x7f && r < 0xa0 || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, w := range wideRunes {
+		if r < w[0] {
+			break
+		}
+		if r <= w[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// displayWidth return columns of s in terminal, escape sequences of color are skipped
+func displayWidth(s string) int {
+	n := 0
+	esc := false
+	for i, r := range s {
+		switch {
+		case esc:
+			if r >= 0x40 && r <= 0x7e && s[i-1] != '\x1b' {
+				esc = false
+			}
+		case r == keyEsc:
+			esc = true
+		default:
+			n += runeWidth(r)
+		}
+	}
+	return n
+}
+
+// IsTerminal return true if f is terminal
+func IsTerminal(f *os.File) bool {
+	return isTerminal(f.Fd())
+}
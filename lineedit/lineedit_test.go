@@ -0,0 +1,192 @@
+package lineedit
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/kamisari/gomem"
+)
+
+func TestLineEditor_readLine(t *testing.T) {
+	tests := []struct {
+		keymap string
+		input  string
+		typed  bool // one byte per read, lone ESC is not escape sequence
+		want   string
+	}{
+		{input: "hello\r", want: "hello"},
+		{input: "helo\x02l\r", want: "hello"},                                    // C-b
+		{input: "world\x01hello \r", want: "hello world"},                        // C-a
+		{input: "hello world\x17\x17\r", want: ""},                               // C-w
+		{input: "hello world\x01\x0b\x19\x19\r", want: "hello worldhello world"}, // C-k C-y
+		{input: "abc\x08\x7fd\r", want: "ad"},                                    // backspace
+		{input: "ab\x14\r", want: "ba"},                                          // C-t
+		{input: "abc\x1b[D\x1b[D\x1b[3~\r", want: "ac"},                          // left, delete
+		{input: "abc\x1b[H1\x1b[F2\r", want: "1abc2"},                            // home, end
+		{input: "one two\x1bb\x1bd\r", want: "one "},                             // M-b M-d
+		{input: "ab\x15c\r", want: "c"},                                          // C-u
+		{keymap: "vi", typed: true, input: "hello\x1bhhx\r", want: "helo"},
+		{keymap: "vi", typed: true, input: "hello world\x1b0dwx\r", want: "ello world"},
+		{keymap: "vi", typed: true, input: "hello world\x1bbD\r", want: "hello "},
+		{keymap: "vi", typed: true, input: "hello\x1b0iX\x1bA!\r", want: "Xhello!"},
+		{keymap: "vi", typed: true, input: "hello\x1bccbye\r", want: "bye"},
+		{keymap: "vi", typed: true, input: "abc\x1b0rz$xp\r", want: "zbc"},
+	}
+	for _, v := range tests {
+		var in io.Reader = strings.NewReader(v.input)
+		if v.typed {
+			in = iotest.OneByteReader(in)
+		}
+		le, err := newLineEditor(in, &bytes.Buffer{}, v.keymap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := le.readLine("> ")
+		if err != nil {
+			t.Errorf("%q: %v", v.input, err)
+			continue
+		}
+		if got != v.want {
+			t.Errorf("%q: want %q but got %q", v.input, v.want, got)
+		}
+	}
+}
+
+func TestLineEditor_History(t *testing.T) {
+	input := "\x1b[A\x1b[A\r" + // second, first
+		"typing\x10\x0e\r" + // back to typing
+		"\x1b[A\x1b[A\x1b[A\x1b[B\r" // second
+	le, err := newLineEditor(strings.NewReader(input), &bytes.Buffer{}, "emacs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	le.History = &gomem.History{Lines: []string{"first", "second"}}
+	var got []string
+	for {
+		line, err := le.readLine("> ")
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, line)
+	}
+	want := []string{"first", "typing", "second"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("want %q but got %q", want, got)
+	}
+	// recorded by caller
+	if len(le.History.Lines) != 2 {
+		t.Errorf("history is modified: %q", le.History.Lines)
+	}
+}
+
+func TestLineEditor_Interrupt(t *testing.T) {
+	le, err := newLineEditor(strings.NewReader("abc\x03\x04"), &bytes.Buffer{}, "emacs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := le.readLine("> "); err != gomem.ErrInterrupt {
+		t.Errorf("want ErrInterrupt but got %v", err)
+	}
+	if _, err := le.readLine("> "); err != io.EOF {
+		t.Errorf("want io.EOF but got %v", err)
+	}
+	if _, err := newLineEditor(strings.NewReader(""), &bytes.Buffer{}, "ed"); err == nil {
+		t.Error("want error for invalid keymap")
+	}
+}
+
+func TestLineEditor_Complete(t *testing.T) {
+	words := []string{"show", "search", "state", "show memo.json", "show memories.json"}
+	complete := func(head string) []string {
+		var c []string
+		for _, w := range words {
+			if strings.HasPrefix(w, head) && strings.Count(w, " ") == strings.Count(head, " ") {
+				c = append(c, w)
+			}
+		}
+		return c
+	}
+	tests := []struct {
+		input string
+		want  string
+		list  bool
+	}{
+		{input: "sh\t\r", want: "show"},
+		{input: "s\t\r", want: "s"},
+		{input: "s\t\t\r", want: "s", list: true},
+		{input: "show m\t\r", want: "show memo"},
+		{input: "show memo.\t\r", want: "show memo.json"},
+		{input: "x\t\r", want: "x"},
+	}
+	for _, v := range tests {
+		out := &bytes.Buffer{}
+		le, err := newLineEditor(strings.NewReader(v.input), out, "emacs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		le.Complete = complete
+		got, err := le.readLine("> ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v.want {
+			t.Errorf("%q: want %q but got %q", v.input, v.want, got)
+		}
+		if listed := strings.Contains(out.String(), "show  search  state"); listed != v.list {
+			t.Errorf("%q: want listed %v but got %v", v.input, v.list, listed)
+		}
+	}
+}
+
+func TestLineEditor_refresh(t *testing.T) {
+	tests := []struct {
+		width int
+		line  string
+		pos   int
+		want  string
+	}{
+		{line: "abc", pos: 1, want: "\r> abc\x1b[J\r\x1b[3C"},
+		// wide runes
+		{line: "日本語", pos: 2, want: "\r> 日本語\x1b[J\r\x1b[6C"},
+		{line: "éx", pos: 2, want: "\r> éx\x1b[J\r\x1b[3C"},
+		// wrapped
+		{width: 10, line: "abcdefghijkl", pos: 3, want: "\r> abcdefghijkl\x1b[J\x1b[1A\r\x1b[5C"},
+		{width: 10, line: "abcdefghijkl", pos: 12, want: "\r> abcdefghijkl\x1b[J\r\x1b[4C"},
+		{width: 10, line: "abcdefgh", pos: 8, want: "\r> abcdefgh\x1b[J\r\n\r"},
+	}
+	for _, v := range tests {
+		out := &bytes.Buffer{}
+		le, err := newLineEditor(strings.NewReader(""), out, "emacs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		le.width, le.prompt = v.width, "> "
+		le.buf, le.pos = []rune(v.line), v.pos
+		le.refresh()
+		if out.String() != v.want {
+			t.Errorf("%q %d: want %q but got %q", v.line, v.pos, v.want, out.String())
+		}
+	}
+
+	// redraw from first row of wrapped line
+	out := &bytes.Buffer{}
+	le, err := newLineEditor(strings.NewReader(""), out, "emacs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	le.width, le.prompt = 10, "\x1b[32m> \x1b[0m"
+	le.buf = []rune("abcdefghijkl")
+	le.pos = len(le.buf)
+	le.refresh()
+	out.Reset()
+	le.refresh()
+	if !strings.HasPrefix(out.String(), "\x1b[1A\r") || !strings.HasSuffix(out.String(), "\r\x1b[4C") {
+		t.Errorf("unexpected redraw: %q", out.String())
+	}
+}
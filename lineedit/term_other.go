@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package lineedit
+
+// isTerminal line editor is not supported
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, ErrNotTerminal
+}
+
+func termWidth(fd uintptr) int {
+	return 0
+}
@@ -0,0 +1,43 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package lineedit
+
+import "golang.org/x/sys/unix"
+
+// isTerminal return true if fd is terminal
+func isTerminal(fd uintptr) bool {
+	_, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	return err == nil
+}
+
+// makeRaw put terminal into raw mode, return function for restore
+// output processing is kept for newline
+func makeRaw(fd uintptr) (func(), error) {
+	old, err := unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	raw := *old
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(int(fd), ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return func() {
+		unix.IoctlSetTermios(int(fd), ioctlSetTermios, old)
+	}, nil
+}
+
+// termWidth return columns of terminal, 0 if unknown
+func termWidth(fd uintptr) int {
+	ws, err := unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
@@ -0,0 +1,113 @@
+package gomem
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LockFileName name of advisory lock file in Gomems.dir
+const LockFileName = ".gomem.lock"
+
+// ErrLocked another session holds lock of directory
+var ErrLocked = errors.New("directory is locked by another session")
+
+// errLockHeld returned by openLockFile if lock is held by another open file
+var errLockHeld = errors.New("lock is held")
+
+// LockError returned by GomemsNew when the lock file held by another process
+type LockError struct {
+	Path string // path to lock file
+	Pid  int    // pid of holder, 0 if unknown
+}
+
+func (e *LockError) Error() string {
+	if e.Pid == 0 {
+		return fmt.Sprintf("%v: %s", ErrLocked, e.Path)
+	}
+	return fmt.Sprintf("%v: %s: pid %d", ErrLocked, e.Path, e.Pid)
+}
+
+// IsLocked return true if err is caused by lock of another session
+func IsLocked(err error) bool {
+	if err == ErrLocked {
+		return true
+	}
+	_, ok := err.(*LockError)
+	return ok
+}
+
+// acquireLock open and lock lock file in dir by openLockFile of platform
+// lock is held until releaseLock or exit of process,
+// so lock file left by dead process is reused whatever its content
+func acquireLock(dir string) (*os.File, error) {
+	lockpath := filepath.Join(dir, LockFileName)
+	for i := 0; i < 3; i++ {
+		f, err := openLockFile(lockpath)
+		if err == errLockHeld {
+			return nil, &LockError{Path: lockpath, Pid: readLockPid(lockpath)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		// removed by releaseLock of previous holder after open, retry with new file
+		if !isLockPath(f, lockpath) {
+			f.Close()
+			continue
+		}
+		if err := writeLockPid(f); err != nil {
+			os.Remove(lockpath)
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	return nil, &LockError{Path: lockpath}
+}
+
+// isLockPath return true if f is file at lockpath
+func isLockPath(f *os.File, lockpath string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(lockpath)
+	return err == nil && os.SameFile(fi, pi)
+}
+
+// writeLockPid replace content of f by pid of this process, for LockError
+func writeLockPid(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// readLockPid return 0 if unknown
+func readLockPid(lockpath string) int {
+	b, err := ioutil.ReadFile(lockpath)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// releaseLock remove lock file then unlock it by close
+func releaseLock(f *os.File) error {
+	err := os.Remove(f.Name())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!windows
+
+package gomem
+
+import "os"
+
+// openLockFile create lockpath exclusively
+// lock file left by dead process is held until removed by hand
+func openLockFile(lockpath string) (*os.File, error) {
+	f, err := os.OpenFile(lockpath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return nil, errLockHeld
+	}
+	return f, err
+}
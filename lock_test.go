@@ -0,0 +1,118 @@
+package gomem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGomemsNew_Lock(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs, err := GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFileName)); err != nil {
+		t.Fatalf("lock file is not created: %v", err)
+	}
+
+	// held by this session
+	if _, err := GomemsNew(dir); !IsLocked(err) {
+		t.Fatalf("want lock error but got %v", err)
+	}
+	ro, err := GomemsNewReadOnly(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ro.IsReadOnly() {
+		t.Error("want read only")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"title": "a"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ro.IncludeJSON(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ro.Remove("a.json"); err != ErrReadOnly {
+		t.Errorf("want ErrReadOnly but got %v", err)
+	}
+	if err := ro.Gmap["a.json"].WriteFile(); err != ErrReadOnly {
+		t.Errorf("want ErrReadOnly but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.json")); err != nil {
+		t.Errorf("file is changed by read only session: %v", err)
+	}
+	if err := ro.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if err := gs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFileName)); !os.IsNotExist(err) {
+		t.Fatalf("lock file is not removed: %v", err)
+	}
+	gs, err = GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGomemsNew_StaleLock(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "stalelock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// left by dead process, not held whatever content
+	tests := []string{
+		"",
+		"invalid\n",
+		"999999999\n",
+	}
+	for _, data := range tests {
+		if err := ioutil.WriteFile(filepath.Join(dir, LockFileName), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gs, err := GomemsNew(dir)
+		if err != nil {
+			t.Errorf("lock:%q: %v", data, err)
+			continue
+		}
+		if err := gs.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestGomemsNew_LockWithoutPid(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "lockwithoutpid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// held by peer before write pid
+	f, err := openLockFile(filepath.Join(dir, LockFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = GomemsNew(dir)
+	if le, ok := err.(*LockError); !ok || le.Pid != 0 {
+		t.Errorf("want lock error without pid but got %v", err)
+	}
+	if err := releaseLock(f); err != nil {
+		t.Fatal(err)
+	}
+	gs, err := GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.Close(); err != nil {
+		t.Error(err)
+	}
+}
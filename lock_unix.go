@@ -0,0 +1,26 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package gomem
+
+import (
+	"os"
+	"syscall"
+)
+
+// openLockFile open or create lockpath and lock it by flock
+// return errLockHeld if locked by another open file
+func openLockFile(lockpath string) (*os.File, error) {
+	f, err := os.OpenFile(lockpath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errLockHeld
+		}
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,28 @@
+package gomem
+
+import (
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation ERROR_SHARING_VIOLATION, not defined in syscall
+const errorSharingViolation syscall.Errno = 32
+
+// openLockFile open or create lockpath without write sharing
+// return errLockHeld if opened by another session
+func openLockFile(lockpath string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(lockpath)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_DELETE, nil,
+		syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err == errorSharingViolation {
+		return nil, errLockHeld
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: lockpath, Err: err}
+	}
+	return os.NewFile(uintptr(h), lockpath), nil
+}
@@ -0,0 +1,106 @@
+package gomem
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// SchemaVersion version of JSON written by WriteFile
+// files without version field are version 0
+const SchemaVersion = 2
+
+// Migration upgrade document from version From to From+1
+type Migration struct {
+	From        int
+	Description string
+	// Migrate modify doc decoded from file, version field is updated by caller
+	// key is path of file in Gomems.dir, empty if not in Gomems
+	Migrate func(key string, doc map[string]interface{}) error
+}
+
+// migrations registry, key: Migration.From
+var migrations = make(map[int]Migration)
+
+// RegisterMigration add m to registry
+// if m.From is already registered then panic
+func RegisterMigration(m Migration) {
+	if _, ok := migrations[m.From]; ok {
+		panic(fmt.Sprintf("gomem.RegisterMigration: version %d is already registered", m.From))
+	}
+	migrations[m.From] = m
+}
+
+// MigrationPath return migrations for upgrade from version to SchemaVersion
+func MigrationPath(from int) ([]Migration, error) {
+	var path []Migration
+	for v := from; v < SchemaVersion; v++ {
+		m, ok := migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("migration: not found migration from version %d", v)
+		}
+		path = append(path, m)
+	}
+	return path, nil
+}
+
+func init() {
+	RegisterMigration(Migration{
+		From:        0,
+		Description: "add version field",
+		Migrate:     func(key string, doc map[string]interface{}) error { return nil },
+	})
+}
+
+// decodeJSON decode b of key and migrate to SchemaVersion if needed
+// return version of b
+func decodeJSON(b []byte, key string) (JSON, int, error) {
+	var j JSON
+	var probe struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return j, 0, err
+	}
+	if probe.Version > SchemaVersion {
+		return j, probe.Version, fmt.Errorf("unsupported schema version %d, newer than %d", probe.Version, SchemaVersion)
+	}
+	if probe.Version < SchemaVersion {
+		path, err := MigrationPath(probe.Version)
+		if err != nil {
+			return j, probe.Version, err
+		}
+		doc := make(map[string]interface{})
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return j, probe.Version, err
+		}
+		for _, m := range path {
+			if err := m.Migrate(key, doc); err != nil {
+				return j, probe.Version, fmt.Errorf("migration from version %d: %v", m.From, err)
+			}
+			doc["version"] = m.From + 1
+		}
+		if b, err = json.Marshal(doc); err != nil {
+			return j, probe.Version, err
+		}
+	}
+	err := json.Unmarshal(b, &j)
+	return j, probe.Version, err
+}
+
+// Version return schema version of file at last ReadFile
+func (g *Gomem) Version() int {
+	return g.version
+}
+
+// Outdated return sorted keys of Gomem read from older schema version
+func (gs *Gomems) Outdated() []string {
+	var keys []string
+	for key, g := range gs.Gmap {
+		if g.version < SchemaVersion {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
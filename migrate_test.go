@@ -0,0 +1,91 @@
+package gomem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		in          string
+		wantVersion int
+		wantTitle   string
+		wantErr     bool
+	}{
+		{in: `{"title": "old", "content": []}`, wantVersion: 0, wantTitle: "old"},
+		{in: fmt.Sprintf(`{"version": %d, "title": "current"}`, SchemaVersion), wantVersion: SchemaVersion, wantTitle: "current"},
+		{in: `{"version": 999, "title": "future"}`, wantVersion: 999, wantErr: true},
+		{in: `{"title": `, wantErr: true},
+	}
+	for _, v := range tests {
+		j, version, err := decodeJSON([]byte(v.in), "")
+		if v.wantErr {
+			if err == nil {
+				t.Errorf("%s: want error", v.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", v.in, err)
+			continue
+		}
+		if version != v.wantVersion || j.Title != v.wantTitle || j.Version != SchemaVersion {
+			t.Errorf("%s: unexpected version:%d title:%q j.Version:%d", v.in, version, j.Title, j.Version)
+		}
+	}
+}
+
+func TestMigrationPath(t *testing.T) {
+	for v := 0; v < SchemaVersion; v++ {
+		path, err := MigrationPath(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(path) != SchemaVersion-v {
+			t.Errorf("from %d: want %d migrations but got %d", v, SchemaVersion-v, len(path))
+		}
+	}
+	if _, err := MigrationPath(-1); err == nil {
+		t.Error("want error for unregistered version")
+	}
+}
+
+func TestGomems_Outdated(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"old.json": `{"title": "old", "content": []}`,
+		"new.json": fmt.Sprintf(`{"version": %d, "title": "new", "content": []}`, SchemaVersion),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gs, err := GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.Close()
+	if keys := gs.Outdated(); len(keys) != 1 || keys[0] != "old.json" {
+		t.Fatalf("want [old.json] but got %q", keys)
+	}
+	if err := gs.Gmap["old.json"].WriteFile(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := gs.Outdated(); len(keys) != 0 {
+		t.Errorf("want no outdated after write but got %q", keys)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "old.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), fmt.Sprintf(`"version": %d`, SchemaVersion)) {
+		t.Errorf("version is not written: %s", b)
+	}
+}
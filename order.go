@@ -0,0 +1,85 @@
+package gomem
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeySorts accepted sort names of Keys
+var KeySorts = []string{"key", "title", "modified", "created"}
+
+// ModTime return g.J.Updated if set, else modified time of file at last ReadFile or WriteFile
+// zero if unknown
+func (g *Gomem) ModTime() time.Time {
+	if g.J.Updated != nil {
+		return *g.J.Updated
+	}
+	if g.disk != nil {
+		return g.disk.modTime
+	}
+	return time.Time{}
+}
+
+// timeOf return zero if t is nil
+func timeOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// Keys return keys of gs.Gmap sorted by "key", "title", "modified" or "created"
+// "modified" and "created" are from old to new, unknown time is first
+// tie is sorted by key, if reverse then reverse all
+func (gs *Gomems) Keys(by string, reverse bool) ([]string, error) {
+	var less func(a, b *Gomem) bool
+	switch by {
+	case "", "key":
+	case "title":
+		less = func(a, b *Gomem) bool { return a.J.Title < b.J.Title }
+	case "modified":
+		less = func(a, b *Gomem) bool { return a.ModTime().Before(b.ModTime()) }
+	case "created":
+		less = func(a, b *Gomem) bool { return timeOf(a.J.Created).Before(timeOf(b.J.Created)) }
+	default:
+		return nil, fmt.Errorf("invalid sort %q: require %s", by, strings.Join(KeySorts, ", "))
+	}
+	keys := make([]string, 0, len(gs.Gmap))
+	for key := range gs.Gmap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if less != nil {
+			a, b := gs.Gmap[keys[i]], gs.Gmap[keys[j]]
+			if less(a, b) {
+				return true
+			}
+			if less(b, a) {
+				return false
+			}
+		}
+		return keys[i] < keys[j]
+	})
+	if reverse {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+	return keys, nil
+}
+
+// Range call f in order of Keys(by, reverse), stop if f return false
+func (gs *Gomems) Range(by string, reverse bool, f func(key string, g *Gomem) bool) error {
+	keys, err := gs.Keys(by, reverse)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if !f(key, gs.Gmap[key]) {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package gomem
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGomems_Keys(t *testing.T) {
+	at := func(h int) *time.Time {
+		t := time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC)
+		return &t
+	}
+	gs := &Gomems{Gmap: map[string]*Gomem{
+		"a.json":     {J: JSON{Title: "zzz", Created: at(3), Updated: at(5)}},
+		"b.json":     {J: JSON{Title: "aaa", Created: at(1)}, disk: &diskState{modTime: *at(9)}},
+		"c.json":     {J: JSON{Title: "mmm", Updated: at(1)}},
+		"sub/d.json": {J: JSON{Title: "aaa", Created: at(2), Updated: at(7)}},
+	}}
+	tests := []struct {
+		by      string
+		reverse bool
+		want    string
+	}{
+		{by: "", want: "[a.json b.json c.json sub/d.json]"},
+		{by: "key", reverse: true, want: "[sub/d.json c.json b.json a.json]"},
+		{by: "title", want: "[b.json sub/d.json c.json a.json]"},
+		{by: "title", reverse: true, want: "[a.json c.json sub/d.json b.json]"},
+		{by: "modified", want: "[c.json a.json sub/d.json b.json]"},
+		{by: "created", want: "[c.json b.json sub/d.json a.json]"},
+	}
+	for _, v := range tests {
+		for i := 0; i < 5; i++ {
+			keys, err := gs.Keys(v.by, v.reverse)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(keys) != v.want {
+				t.Errorf("%s reverse:%v: want %s but got %s", v.by, v.reverse, v.want, keys)
+				break
+			}
+		}
+	}
+	if _, err := gs.Keys("size", false); err == nil {
+		t.Error("want error for invalid sort")
+	}
+
+	var keys []string
+	err := gs.Range("title", false, func(key string, g *Gomem) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(keys) != "[b.json sub/d.json]" {
+		t.Errorf("unexpected Range: %s", keys)
+	}
+}
@@ -0,0 +1,205 @@
+package gomem
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Priority of todo, 1 is highest and 5 is lowest, 0 is none
+// A to E are same as 1 to 5
+type Priority int
+
+// ParsePriority accept "A" to "E" and "1" to "5", "none" for 0
+func ParsePriority(s string) (Priority, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "NONE" {
+		return 0, nil
+	}
+	if len(s) == 1 && s[0] >= 'A' && s[0] <= 'E' {
+		return Priority(s[0]-'A') + 1, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 1 && n <= 5 {
+		return Priority(n), nil
+	}
+	return 0, fmt.Errorf("invalid priority %q: require A-E or 1-5", s)
+}
+
+func (p Priority) String() string {
+	if p < 1 || p > 5 {
+		return ""
+	}
+	return string(rune('A' + p - 1))
+}
+
+// SetPriority set priority of todo
+func (g *Gomem) SetPriority(p Priority) error {
+	if !g.IsTodo() {
+		return g.errNotTodo()
+	}
+	if p < 0 || p > 5 {
+		return fmt.Errorf("invalid priority: %d", p)
+	}
+	g.J.Todo.Priority = p
+	g.SetDirty()
+	return nil
+}
+
+// TodoSorts accepted sort names of SortTodos
+var TodoSorts = []string{"priority", "due", "created"}
+
+// SortTodos return keys of todo in gs.Gmap that match all filters
+// by "priority" sorts by priority, due, created, "due" by due, priority, created
+// and "created" by created, priority, due
+// no priority, no due and unknown created are last, tie is sorted by key
+func (gs *Gomems) SortTodos(by string, filters ...TodoFilter) ([]string, error) {
+	var order []func(a, b *Todo, ca, cb *time.Time) int
+	switch by {
+	case "", "priority":
+		order = append(order, cmpPriority, cmpDue, cmpCreated)
+	case "due":
+		order = append(order, cmpDue, cmpPriority, cmpCreated)
+	case "created":
+		order = append(order, cmpCreated, cmpPriority, cmpDue)
+	default:
+		return nil, fmt.Errorf("invalid sort %q: require %s", by, strings.Join(TodoSorts, ", "))
+	}
+	now := time.Now()
+	var keys []string
+	for key, g := range gs.Gmap {
+		if !g.IsTodo() || !matchAll(g, filters, now) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := gs.Gmap[keys[i]].J, gs.Gmap[keys[j]].J
+		for _, cmp := range order {
+			if c := cmp(a.Todo, b.Todo, a.Created, b.Created); c != 0 {
+				return c < 0
+			}
+		}
+		return keys[i] < keys[j]
+	})
+	return keys, nil
+}
+
+func cmpPriority(a, b *Todo, _, _ *time.Time) int {
+	pa, pb := a.Priority, b.Priority
+	// none is last
+	if pa == 0 {
+		pa = 6
+	}
+	if pb == 0 {
+		pb = 6
+	}
+	return int(pa) - int(pb)
+}
+func cmpDue(a, b *Todo, _, _ *time.Time) int {
+	return cmpTime(a.Due, b.Due)
+}
+func cmpCreated(_, _ *Todo, ca, cb *time.Time) int {
+	return cmpTime(ca, cb)
+}
+
+// cmpTime nil is last
+func cmpTime(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	case a.Before(*b):
+		return -1
+	case b.Before(*a):
+		return 1
+	}
+	return 0
+}
+
+// TodoFilter condition of SortTodos
+type TodoFilter struct {
+	Field string // "status", "priority", "tag" or "due"
+	Value string
+}
+
+// ParseTodoFilter parse "field:value"
+// status:open, priority:B for B or higher, tag:work, due:overdue|today|upcoming|none
+func ParseTodoFilter(s string) (TodoFilter, error) {
+	i := strings.Index(s, ":")
+	if i == -1 {
+		return TodoFilter{}, fmt.Errorf("invalid filter %q: require field:value", s)
+	}
+	f := TodoFilter{Field: s[:i], Value: s[i+1:]}
+	switch f.Field {
+	case "status":
+		if _, err := ParseStatus(f.Value); err != nil {
+			return f, err
+		}
+	case "priority":
+		if _, err := ParsePriority(f.Value); err != nil {
+			return f, err
+		}
+	case "tag":
+	case "due":
+		if _, ok := parseDueState(f.Value); !ok {
+			return f, fmt.Errorf("invalid due filter %q: require overdue, today, upcoming or none", f.Value)
+		}
+	default:
+		return f, fmt.Errorf("invalid filter field %q: require status, priority, tag or due", f.Field)
+	}
+	return f, nil
+}
+
+func parseDueState(s string) (DueState, bool) {
+	switch s {
+	case "overdue":
+		return DueOverdue, true
+	case "today":
+		return DueToday, true
+	case "upcoming":
+		return DueUpcoming, true
+	case "none":
+		return DueNone, true
+	}
+	return DueNone, false
+}
+
+// Match return true if g matches f
+func (f TodoFilter) Match(g *Gomem, now time.Time) bool {
+	if !g.IsTodo() {
+		return false
+	}
+	switch f.Field {
+	case "status":
+		return string(g.J.Todo.Status) == f.Value
+	case "priority":
+		p, err := ParsePriority(f.Value)
+		if err != nil {
+			return false
+		}
+		if p == 0 {
+			return g.J.Todo.Priority == 0
+		}
+		return g.J.Todo.Priority != 0 && g.J.Todo.Priority <= p
+	case "tag":
+		return g.HasTag(f.Value)
+	case "due":
+		st, ok := parseDueState(f.Value)
+		return ok && g.J.Todo.DueState(now) == st
+	}
+	return false
+}
+
+func matchAll(g *Gomem, filters []TodoFilter, now time.Time) bool {
+	for _, f := range filters {
+		if !f.Match(g, now) {
+			return false
+		}
+	}
+	return true
+}
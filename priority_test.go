@@ -0,0 +1,105 @@
+package gomem
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Priority
+		wantErr bool
+	}{
+		{in: "A", want: 1},
+		{in: "c", want: 3},
+		{in: "5", want: 5},
+		{in: "none", want: 0},
+		{in: "F", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, v := range tests {
+		p, err := ParsePriority(v.in)
+		if v.wantErr {
+			if err == nil {
+				t.Errorf("%q: want error", v.in)
+			}
+			continue
+		}
+		if err != nil || p != v.want {
+			t.Errorf("%q: want %d but got %d %v", v.in, v.want, p, err)
+		}
+	}
+}
+
+func TestGomems_SortTodos(t *testing.T) {
+	now := time.Now()
+	day := func(n int) *time.Time {
+		d := TruncateDay(now).AddDate(0, 0, n)
+		return &d
+	}
+	gs := &Gomems{Gmap: make(map[string]*Gomem), dir: tmpdir}
+	add := func(key string, p Priority, due, created *time.Time, tags ...string) {
+		g, err := NewTodo(filepath.Join(tmpdir, key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.J.Todo.Priority = p
+		g.J.Todo.Due = due
+		g.J.Created = created
+		g.J.Tags = tags
+		gs.Gmap[key] = g
+	}
+	add("a.json", 0, day(1), day(-3))
+	add("b.json", 2, nil, day(-2), "work")
+	add("c.json", 1, day(5), day(-1))
+	add("d.json", 2, day(-1), nil, "work")
+	add("e.json", 0, nil, nil)
+	gs.Gmap["plain.json"] = &Gomem{}
+
+	tests := []struct {
+		by      string
+		filters []string
+		want    string
+	}{
+		{by: "", want: "[c.json d.json b.json a.json e.json]"},
+		{by: "due", want: "[d.json a.json c.json b.json e.json]"},
+		{by: "created", want: "[a.json b.json c.json d.json e.json]"},
+		{by: "priority", filters: []string{"tag:work"}, want: "[d.json b.json]"},
+		{by: "priority", filters: []string{"priority:B", "due:none"}, want: "[b.json]"},
+		{by: "due", filters: []string{"due:overdue"}, want: "[d.json]"},
+		{by: "due", filters: []string{"priority:none"}, want: "[a.json e.json]"},
+	}
+	for _, v := range tests {
+		var filters []TodoFilter
+		for _, s := range v.filters {
+			f, err := ParseTodoFilter(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filters = append(filters, f)
+		}
+		// map order is random
+		for i := 0; i < 5; i++ {
+			keys, err := gs.SortTodos(v.by, filters...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(keys) != v.want {
+				t.Errorf("%s %v: want %s but got %s", v.by, v.filters, v.want, keys)
+				break
+			}
+		}
+	}
+	if _, err := gs.SortTodos("title"); err == nil {
+		t.Error("want error for invalid sort")
+	}
+	for _, s := range []string{"status", "status:later", "color:red", "due:someday"} {
+		if _, err := ParseTodoFilter(s); err == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
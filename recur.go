@@ -0,0 +1,164 @@
+package gomem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence rule of recurring todo
+type Recurrence struct {
+	Every   string `json:"every"`             // "day", "week", "month" or "days"
+	Weekday string `json:"weekday,omitempty"` // for week, e.g. "Monday"
+	Day     int    `json:"day,omitempty"`     // for month, day of month 1-31
+	Days    int    `json:"days,omitempty"`    // for days, interval
+}
+
+// Completion history of recurring todo
+type Completion struct {
+	Done time.Time  `json:"done"`
+	Due  *time.Time `json:"due,omitempty"`
+}
+
+// ParseRecurrence parse rule of recurrence
+// accept "daily", "weekly:monday", "monthly:15" and "3d" for every 3 days
+// "weekly" and "monthly" without argument use weekday or day of base
+func ParseRecurrence(s string, base time.Time) (*Recurrence, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	name, arg := s, ""
+	if i := strings.Index(s, ":"); i != -1 {
+		name, arg = s[:i], s[i+1:]
+	}
+	switch name {
+	case "daily":
+		if arg == "" {
+			return &Recurrence{Every: "day"}, nil
+		}
+	case "weekly":
+		if arg == "" {
+			return &Recurrence{Every: "week", Weekday: base.Weekday().String()}, nil
+		}
+		for wd := time.Sunday; wd <= time.Saturday; wd++ {
+			if n := strings.ToLower(wd.String()); arg == n || arg == n[:3] {
+				return &Recurrence{Every: "week", Weekday: wd.String()}, nil
+			}
+		}
+	case "monthly":
+		if arg == "" {
+			return &Recurrence{Every: "month", Day: base.Day()}, nil
+		}
+		if day, err := strconv.Atoi(arg); err == nil && day >= 1 && day <= 31 {
+			return &Recurrence{Every: "month", Day: day}, nil
+		}
+	default:
+		if arg == "" && strings.HasSuffix(s, "d") {
+			if n, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && n > 0 {
+				return &Recurrence{Every: "days", Days: n}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid recurrence %q: require daily, weekly[:weekday], monthly[:day] or Nd", s)
+}
+
+func (r *Recurrence) String() string {
+	switch r.Every {
+	case "day":
+		return "daily"
+	case "week":
+		return "weekly:" + strings.ToLower(r.Weekday)
+	case "month":
+		return "monthly:" + strconv.Itoa(r.Day)
+	case "days":
+		return strconv.Itoa(r.Days) + "d"
+	}
+	return "invalid:" + r.Every
+}
+
+// Next return next date of r after t, result is 00:00 in t.Location()
+func (r *Recurrence) Next(t time.Time) (time.Time, error) {
+	day := TruncateDay(t)
+	switch r.Every {
+	case "day":
+		return day.AddDate(0, 0, 1), nil
+	case "days":
+		if r.Days <= 0 {
+			break
+		}
+		return day.AddDate(0, 0, r.Days), nil
+	case "week":
+		for wd := time.Sunday; wd <= time.Saturday; wd++ {
+			if wd.String() == r.Weekday {
+				days := (int(wd) - int(day.Weekday()) + 7) % 7
+				if days == 0 {
+					days = 7
+				}
+				return day.AddDate(0, 0, days), nil
+			}
+		}
+	case "month":
+		if r.Day < 1 || r.Day > 31 {
+			break
+		}
+		y, m, _ := day.Date()
+		for i := 0; i < 2; i++ {
+			// clamp to last day of month
+			last := time.Date(y, m+time.Month(i)+1, 0, 0, 0, 0, 0, day.Location()).Day()
+			d := r.Day
+			if d > last {
+				d = last
+			}
+			next := time.Date(y, m+time.Month(i), d, 0, 0, 0, 0, day.Location())
+			if next.After(day) {
+				return next, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid recurrence: %+v", *r)
+}
+
+// SetRecur set recurrence rule of todo, nil for clear
+// if due is nil then set next date from today
+func (g *Gomem) SetRecur(r *Recurrence) error {
+	if !g.IsTodo() {
+		return g.errNotTodo()
+	}
+	if r != nil && g.J.Todo.Due == nil {
+		next, err := r.Next(time.Now().AddDate(0, 0, -1))
+		if err != nil {
+			return err
+		}
+		g.J.Todo.Due = &next
+	}
+	g.J.Todo.Recur = r
+	g.SetDirty()
+	return nil
+}
+
+// rollRecur record completion to history and move due to next date after now
+// items are unchecked, remind is moved same as due
+func (t *Todo) rollRecur(now time.Time) error {
+	base := now
+	if t.Due != nil {
+		base = *t.Due
+	}
+	next, err := t.Recur.Next(base)
+	if err != nil {
+		return err
+	}
+	for !next.After(TruncateDay(now)) {
+		if next, err = t.Recur.Next(next); err != nil {
+			return err
+		}
+	}
+	t.History = append(t.History, Completion{Done: now, Due: t.Due})
+	if t.Remind != nil && t.Due != nil {
+		remind := t.Remind.Add(next.Sub(*t.Due))
+		t.Remind = &remind
+	}
+	t.Due = &next
+	for i := range t.Items {
+		t.Items[i].Done = false
+	}
+	return nil
+}
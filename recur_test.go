@@ -0,0 +1,94 @@
+package gomem
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecurrence_Next(t *testing.T) {
+	// Wednesday
+	base := time.Date(2026, 1, 14, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rule    string
+		from    time.Time
+		want    string
+		wantStr string
+	}{
+		{rule: "daily", from: base, want: "2026-01-15", wantStr: "daily"},
+		{rule: "3d", from: base, want: "2026-01-17", wantStr: "3d"},
+		{rule: "weekly", from: base, want: "2026-01-21", wantStr: "weekly:wednesday"},
+		{rule: "weekly:fri", from: base, want: "2026-01-16", wantStr: "weekly:friday"},
+		{rule: "monthly", from: base, want: "2026-02-14", wantStr: "monthly:14"},
+		{rule: "monthly:20", from: base, want: "2026-01-20", wantStr: "monthly:20"},
+		{rule: "monthly:31", from: time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), want: "2026-02-28", wantStr: "monthly:31"},
+		{rule: "monthly:31", from: time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), want: "2026-03-31", wantStr: "monthly:31"},
+	}
+	for _, v := range tests {
+		r, err := ParseRecurrence(v.rule, base)
+		if err != nil {
+			t.Errorf("%s: %v", v.rule, err)
+			continue
+		}
+		if r.String() != v.wantStr {
+			t.Errorf("%s: want %s but got %s", v.rule, v.wantStr, r)
+		}
+		next, err := r.Next(v.from)
+		if err != nil {
+			t.Errorf("%s: %v", v.rule, err)
+			continue
+		}
+		if s := next.Format("2006-01-02"); s != v.want {
+			t.Errorf("%s from %s: want %s but got %s", v.rule, v.from.Format("2006-01-02"), v.want, s)
+		}
+	}
+	for _, rule := range []string{"", "hourly", "weekly:someday", "monthly:32", "0d", "-1d"} {
+		if _, err := ParseRecurrence(rule, base); err == nil {
+			t.Errorf("%q: want error", rule)
+		}
+	}
+}
+
+func TestGomem_DoneRecurring(t *testing.T) {
+	g, err := NewTodo(filepath.Join(tmpdir, "chore.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddItem("clean"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.ToggleItem(0); err != nil {
+		t.Fatal(err)
+	}
+	// overdue 2 weeks
+	now := time.Now()
+	due := TruncateDay(now).AddDate(0, 0, -14)
+	g.J.Todo.Due = &due
+	if err := g.SetRecur(&Recurrence{Every: "days", Days: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetStatus(StatusDone); err != nil {
+		t.Fatal(err)
+	}
+	todo := g.J.Todo
+	if todo.Status != StatusOpen || todo.Completed != nil {
+		t.Errorf("want open: %+v", todo)
+	}
+	if len(todo.History) != 1 || !todo.History[0].Due.Equal(due) {
+		t.Errorf("unexpected history: %+v", todo.History)
+	}
+	if want := due.AddDate(0, 0, 15); !todo.Due.Equal(want) {
+		t.Errorf("want due %v but got %v", want, todo.Due)
+	}
+	if todo.Items[0].Done {
+		t.Error("want item unchecked")
+	}
+
+	// cancel is not rolled
+	if err := g.SetStatus(StatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+	if g.J.Todo.Status != StatusCancelled || len(g.J.Todo.History) != 1 {
+		t.Errorf("unexpected cancel: %+v", g.J.Todo)
+	}
+}
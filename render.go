@@ -0,0 +1,98 @@
+package gomem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Renderer render result of command to output
+type Renderer func(v interface{}) (string, error)
+
+// Renderers default renderers of SubNew by format
+var Renderers = map[string]Renderer{
+	"text":   RenderText,
+	"json":   RenderJSON,
+	"ndjson": RenderNDJSON,
+}
+
+// Formats return sorted names of Renderers
+func Formats() []string {
+	return formatsOf(Renderers)
+}
+
+func formatsOf(m map[string]Renderer) []string {
+	list := make([]string, 0, len(m))
+	for format := range m {
+		list = append(list, format)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Text result of Addf and Addfa
+// rendered as is in text, {"message": text} in json
+type Text string
+
+func (t Text) String() string {
+	return string(t)
+}
+
+// MarshalJSON for json and ndjson
+func (t Text) MarshalJSON() ([]byte, error) {
+	return marshal(struct {
+		Message string `json:"message"`
+	}{Message: string(t)}, "")
+}
+
+// marshal v without HTML escape, indent is empty for one line
+func marshal(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// RenderText render v by String if v is fmt.Stringer
+func RenderText(v interface{}) (string, error) {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// RenderJSON render v as indented JSON
+func RenderJSON(v interface{}) (string, error) {
+	b, err := marshal(v, "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// RenderNDJSON render elements of slice in each line, other value in one line
+func RenderNDJSON(v interface{}) (string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		b, err := marshal(v, "")
+		return string(b), err
+	}
+	var buf bytes.Buffer
+	for i := 0; i < rv.Len(); i++ {
+		b, err := marshal(rv.Index(i).Interface(), "")
+		if err != nil {
+			return "", err
+		}
+		if i != 0 {
+			buf.WriteByte('\n')
+		}
+		buf.Write(b)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,35 @@
+package gomem
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		render Renderer
+		v      interface{}
+		want   string
+	}{
+		{render: RenderText, v: Text("a\nb"), want: "a\nb"},
+		{render: RenderText, v: 42, want: "42"},
+		{render: RenderJSON, v: Text("hello"), want: "{\n  \"message\": \"hello\"\n}"},
+		{render: RenderJSON, v: []int{}, want: "[]"},
+		{render: RenderNDJSON, v: []Text{"a", "b"}, want: `{"message":"a"}` + "\n" + `{"message":"b"}`},
+		{render: RenderNDJSON, v: []int{}, want: ""},
+		{render: RenderNDJSON, v: map[string]int{"a": 1}, want: `{"a":1}`},
+		{render: RenderNDJSON, v: Text("<a&b>"), want: `{"message":"<a&b>"}`},
+	}
+	for i, v := range tests {
+		got, err := v.render(v.v)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if got != v.want {
+			t.Errorf("%d: want %q but got %q", i, v.want, got)
+		}
+	}
+	if _, err := RenderJSON(func() {}); err == nil {
+		t.Error("want error for unsupported type")
+	}
+}
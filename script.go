@@ -0,0 +1,313 @@
+package gomem
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// script for SubCommands.RunScript, one command per line as Repl
+//
+//	# comment         to end of line, outside quotes and after whitespace
+//	name=value        set variable, quote value that has whitespace
+//	$name ${name}     expanded except in single quotes, \$ for literal,
+//	                  value is split by whitespace outside double quotes,
+//	                  quotes and backslash in value are literal,
+//	                  $1 to $9 are arguments of script, $0 is name of script,
+//	                  unset variable is read from environment, else error
+//	set -e, set +e    stop or continue on error, default is stop
+//	cmd args <<EOF    following lines until EOF are input of cmd,
+//	                  <<'EOF' for no expansion in lines
+//	exit              stop script without error, not command "exit" of Repl
+
+// maxScriptDepth limit of nested RunScript
+const maxScriptDepth = 8
+
+// ScriptError failure of script at line
+type ScriptError struct {
+	Name string // name of script
+	Line int    // from 1
+	Cmd  string // empty if not command error
+	Err  error
+}
+
+func (e *ScriptError) Error() string {
+	if e.Cmd == "" {
+		return fmt.Sprintf("%s:%d: %v", e.Name, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %s: %v", e.Name, e.Line, e.Cmd, e.Err)
+}
+
+var (
+	reVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	reHereDoc = regexp.MustCompile(`(^|\s)<<('?)([A-Za-z_][A-Za-z0-9_]*)('?)\s*$`)
+)
+
+// RunScript run lines of r by Run, name is for error
+// rendered results are written to sub.w, errors of set +e are written to ew
+// sub.ScriptInput is called with here-document or empty input before each command
+// return *ScriptError of first error if set -e
+func (sub *SubCommands) RunScript(name string, r io.Reader, args []string, ew io.Writer) error {
+	if sub.scriptDepth >= maxScriptDepth {
+		return fmt.Errorf("script nested too deep: %s", name)
+	}
+	sub.scriptDepth++
+	defer func() { sub.scriptDepth-- }()
+
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	vars := map[string]string{"0": name}
+	for i, arg := range args {
+		vars[strconv.Itoa(i+1)] = arg
+	}
+	lookup := func(key string) (string, error) {
+		if v, ok := vars[key]; ok {
+			return v, nil
+		}
+		if v, ok := os.LookupEnv(key); ok {
+			return v, nil
+		}
+		return "", fmt.Errorf("undefined variable: %s", key)
+	}
+
+	stop := true
+	for n := 0; n < len(lines); n++ {
+		lineNo := n + 1
+		fail := func(cmd string, err error) error {
+			serr := &ScriptError{Name: name, Line: lineNo, Cmd: cmd, Err: err}
+			if stop {
+				return serr
+			}
+			fmt.Fprintln(ew, serr)
+			return nil
+		}
+
+		code := stripComment(lines[n])
+		var input []string
+		expandInput := true
+		if m := reHereDoc.FindStringSubmatchIndex(code); m != nil {
+			quote, tag := code[m[4]:m[5]], code[m[6]:m[7]]
+			if quote != code[m[8]:m[9]] {
+				return &ScriptError{Name: name, Line: lineNo, Err: fmt.Errorf("invalid here-document: %s", code[m[0]:])}
+			}
+			expandInput = quote == ""
+			code = code[:m[0]]
+			end := n + 1
+			for ; end < len(lines) && strings.TrimSpace(lines[end]) != tag; end++ {
+				input = append(input, lines[end])
+			}
+			if end == len(lines) {
+				return &ScriptError{Name: name, Line: lineNo, Err: fmt.Errorf("here-document is not terminated by %s", tag)}
+			}
+			n = end
+		}
+
+		line, err := expandVars(code, lookup, true)
+		if err != nil {
+			if err := fail("", err); err != nil {
+				return err
+			}
+			continue
+		}
+		if expandInput {
+			for i := range input {
+				if input[i], err = expandVars(input[i], lookup, false); err != nil {
+					break
+				}
+			}
+			if err != nil {
+				if err := fail("", err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		tokens, err := SplitArgs(line)
+		if err != nil {
+			if err := fail("", err); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+
+		if i := strings.Index(tokens[0], "="); i > 0 && reVarName.MatchString(tokens[0][:i]) {
+			if len(tokens) != 1 {
+				if err := fail("", fmt.Errorf("invalid assignment: %s", strings.TrimSpace(line))); err != nil {
+					return err
+				}
+				continue
+			}
+			vars[tokens[0][:i]] = tokens[0][i+1:]
+			continue
+		}
+		if tokens[0] == "set" && len(tokens) == 2 && (tokens[1] == "-e" || tokens[1] == "+e") {
+			stop = tokens[1] == "-e"
+			continue
+		}
+		if tokens[0] == "exit" && len(tokens) == 1 {
+			return nil
+		}
+
+		if sub.ScriptInput != nil {
+			var text string
+			if len(input) != 0 {
+				text = strings.Join(input, "\n") + "\n"
+			}
+			sub.ScriptInput(strings.NewReader(text))
+		}
+		result, err := sub.Run(tokens[0], tokens[1:])
+		if err == ErrValidExit {
+			sub.printResult(result)
+			return nil
+		}
+		if err != nil {
+			if err := fail(tokens[0], err); err != nil {
+				return err
+			}
+			continue
+		}
+		sub.printResult(result)
+	}
+	return nil
+}
+
+// printResult print result to sub.w without trailing newline, empty result is not printed
+func (sub *SubCommands) printResult(result interface{}) {
+	s, err := sub.Render(result)
+	if err != nil {
+		fmt.Fprintln(sub.w, "render:", err)
+		return
+	}
+	if s != "" {
+		fmt.Fprintln(sub.w, strings.TrimSuffix(s, "\n"))
+	}
+}
+
+// stripComment remove "#" to end of line
+// "#" is comment at start of line or after whitespace, outside quotes
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && quote != '\'':
+			i++ // escaped
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// expandVars replace $name and ${name} by lookup, \$ is literal $
+// if quotes then s is for SplitArgs: single quoted text is not expanded
+// and value is escaped by escapeValue
+func expandVars(s string, lookup func(string) (string, error), quotes bool) (string, error) {
+	var buf strings.Builder
+	inSingle, inDouble := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inSingle:
+			if c == '\'' {
+				inSingle = false
+			}
+			buf.WriteByte(c)
+		case quotes && c == '"':
+			inDouble = !inDouble
+			buf.WriteByte(c)
+		case quotes && !inDouble && c == '\'':
+			inSingle = true
+			buf.WriteByte(c)
+		case c == '\\' && i+1 < len(s):
+			if s[i+1] != '$' {
+				buf.WriteByte(c)
+			}
+			buf.WriteByte(s[i+1])
+			i++
+		case c == '$' && i+1 < len(s):
+			name, end := varName(s, i+1)
+			if name == "" {
+				buf.WriteByte(c)
+				continue
+			}
+			v, err := lookup(name)
+			if err != nil {
+				return "", err
+			}
+			if quotes {
+				v = escapeValue(v, inDouble)
+			}
+			buf.WriteString(v)
+			i = end - 1
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), nil
+}
+
+// escapeValue escape quotes and backslash of v for SplitArgs
+// whitespace is kept to split value outside double quotes
+func escapeValue(v string, inDouble bool) string {
+	var buf strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c == '"' || c == '\\' || !inDouble && c == '\'' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// varName return name of variable at s[i:] after "$" and end of reference
+// empty name if not variable
+func varName(s string, i int) (string, int) {
+	if s[i] == '{' {
+		end := strings.IndexByte(s[i:], '}')
+		if end == -1 || !reVarName.MatchString(s[i+1:i+end]) && !isDigit(s[i+1:i+end]) {
+			return "", i
+		}
+		return s[i+1 : i+end], i + end + 1
+	}
+	if s[i] >= '0' && s[i] <= '9' {
+		return s[i : i+1], i + 1
+	}
+	end := i
+	for end < len(s) && (s[end] == '_' || s[end] >= 'A' && s[end] <= 'Z' || s[end] >= 'a' && s[end] <= 'z' || end > i && s[end] >= '0' && s[end] <= '9') {
+		end++
+	}
+	return s[i:end], end
+}
+
+func isDigit(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package gomem
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newScriptSub return SubCommands for script test
+// echo print arguments separated by "|", cat print input
+func newScriptSub(out *bytes.Buffer) *SubCommands {
+	sub := SubNew(&bytes.Buffer{}, out)
+	var input io.Reader
+	sub.ScriptInput = func(r io.Reader) { input = r }
+	sub.Addf("exit", sub.Exit, "")
+	sub.AddCommand(&Command{
+		Name: "echo", MaxArgs: -1,
+		Run: func(a *Args) (interface{}, error) { return strings.Join(a.Args, "|"), nil },
+	})
+	sub.Handle("cat", func() (interface{}, error) {
+		b, err := ioutil.ReadAll(input)
+		return strings.TrimSuffix(string(b), "\n"), err
+	}, "")
+	sub.Addf("fail", func() (string, error) { return "", Failf("failed") }, "")
+	return sub
+}
+
+func TestSubCommands_RunScript(t *testing.T) {
+	os.Setenv("GOMEM_SCRIPT_TEST", "env")
+	defer os.Unsetenv("GOMEM_SCRIPT_TEST")
+	tests := []struct {
+		script  string
+		args    []string
+		want    string
+		wantErr string
+		stderr  string
+	}{
+		{script: "# comment\n\necho a  b # trailing\necho 'a # b' \"c d\"", want: "a|b\na # b|c d\n"},
+		{script: "x=1\nname=\"my memo\"\necho $x ${x}2 \"$name\" $name '$x' \\$x", want: "1|12|my memo|my|memo|$x|$x\n"},
+		{script: "echo $0 $1 $2 \"${1}\"", args: []string{"a b", "c"}, want: "test.gm|a|b|c|a b\n"},
+		{script: "echo $GOMEM_SCRIPT_TEST", want: "env\n"},
+		{script: "echo $GOMEM_SCRIPT_TEST \"it's $x\"\n", wantErr: "test.gm:1: undefined variable: x"},
+		{script: "x=\"it's\"\necho \"$x ok\"", want: "it's ok\n"},
+		{script: "x=a b", wantErr: "test.gm:1: invalid assignment: x=a b"},
+		// quotes and backslash in value are literal, whitespace splits outside double quotes
+		{script: "q='a \"b c\" d'\necho \"$q\"\necho $q", want: "a \"b c\" d\na|\"b|c\"|d\n"},
+		{script: "q='say \"hi\"'\necho bar $q", want: "bar|say|\"hi\"\n"},
+		{script: "q='x\"y'\necho $q \"$q\" ${q}z", want: "x\"y|x\"y|x\"yz\n"},
+		{script: "q=\"it's\"\nr='a\\b'\necho $q \"$r\" $r\nr2=$r\necho $r2", want: "it's|a\\b|a\\b\na\\b\n"},
+		{script: "echo $1 \"$2\"", args: []string{"'x", "a \"b\""}, want: "'x|a \"b\"\n"},
+		// stop on error
+		{script: "echo 1\nfail\necho 2", want: "1\n", wantErr: "test.gm:2: fail: failed"},
+		{script: "set +e\nfail\nnothing\nset -e\necho 1\nfail\necho 2", want: "1\n",
+			wantErr: "test.gm:6: fail: failed",
+			stderr:  "test.gm:2: fail: failed\ntest.gm:3: nothing: unknown command\n"},
+		{script: "echo 1\nexit\necho 2", want: "1\n"},
+		{script: "echo \"a", wantErr: "test.gm:1: unterminated quote: \"a"},
+		// here-document
+		{script: "x=1\ncat <<EOF\ntitle $x\n  content\n  EOF\ncat\necho end", want: "title 1\n  content\nend\n"},
+		{script: "cat <<'EOF' # comment\n$x\nEOF", want: "$x\n"},
+		{script: "cat <<EOF\nline", wantErr: "test.gm:1: here-document is not terminated by EOF"},
+	}
+	for _, v := range tests {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		sub := newScriptSub(out)
+		err := sub.RunScript("test.gm", strings.NewReader(v.script), v.args, errOut)
+		var gotErr string
+		if err != nil {
+			gotErr = err.Error()
+		}
+		if out.String() != v.want || gotErr != v.wantErr || errOut.String() != v.stderr {
+			t.Errorf("%q:\nwant %q %q %q\ngot  %q %q %q", v.script, v.want, v.wantErr, v.stderr, out.String(), gotErr, errOut.String())
+		}
+	}
+}
+
+func TestSubCommands_RunScriptNested(t *testing.T) {
+	out := &bytes.Buffer{}
+	sub := newScriptSub(out)
+	sub.Handle("self", func() (interface{}, error) {
+		return nil, sub.RunScript("self.gm", strings.NewReader("self"), nil, out)
+	}, "")
+	err := sub.RunScript("self.gm", strings.NewReader("self"), nil, out)
+	if err == nil || !strings.Contains(err.Error(), "script nested too deep") {
+		t.Errorf("want nested error but got %v", err)
+	}
+	if sub.scriptDepth != 0 {
+		t.Errorf("depth is not restored: %d", sub.scriptDepth)
+	}
+}
+
+func TestSubCommands_RunScriptExit(t *testing.T) {
+	// exit stops script even if command exit does not
+	out := &bytes.Buffer{}
+	sub := newScriptSub(out)
+	exits := 0
+	sub.Handle("exit", func() (interface{}, error) { exits++; return nil, Failf("cancel exit") }, "")
+	if err := sub.RunScript("test.gm", strings.NewReader("echo 1\nexit\necho 2"), nil, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "1\n" || exits != 0 {
+		t.Errorf("want stop by exit but got %q, exits %d", out.String(), exits)
+	}
+}
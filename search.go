@@ -0,0 +1,148 @@
+package gomem
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SearchMode mode of Search
+type SearchMode int
+
+// SearchMode values
+const (
+	// SearchSubstring case-insensitive substring of whole query
+	SearchSubstring SearchMode = iota
+	// SearchRegexp query is regular expression
+	SearchRegexp
+	// SearchAll query is whitespace separated terms
+	// match if all terms are in title or lines, case-insensitive
+	SearchAll
+	// SearchRanked query is whitespace separated words
+	// match if any word is in title, lines or tags, sorted by BM25 score with index
+	SearchRanked
+)
+
+// SearchResult matched Gomem of Search
+type SearchResult struct {
+	Key        string
+	Title      string
+	TitleSpans [][2]int // byte offsets of hits in Title
+	Lines      []LineMatch
+	Score      float64 // relevance of SearchRanked, 0 for other modes
+}
+
+// LineMatch matched line in JSON.Lines()
+type LineMatch struct {
+	Index int // index of line from 0
+	Text  string
+	Spans [][2]int // byte offsets of hits in Text
+}
+
+// compileQuery return patterns, all patterns must match
+func compileQuery(query string, mode SearchMode) ([]*regexp.Regexp, error) {
+	var terms []string
+	switch mode {
+	case SearchSubstring:
+		if query == "" {
+			break
+		}
+		terms = append(terms, "(?i)"+regexp.QuoteMeta(query))
+	case SearchRegexp:
+		if query == "" {
+			break
+		}
+		terms = append(terms, query)
+	case SearchAll:
+		for _, t := range strings.Fields(query) {
+			terms = append(terms, "(?i)"+regexp.QuoteMeta(t))
+		}
+	default:
+		return nil, fmt.Errorf("invalid search mode: %d", mode)
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	patterns := make([]*regexp.Regexp, 0, len(terms))
+	for _, t := range terms {
+		re, err := regexp.Compile(t)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// findSpans return sorted and merged spans of all patterns in s
+// and matched flags for each pattern
+func findSpans(s string, patterns []*regexp.Regexp, matched []bool) [][2]int {
+	var spans [][2]int
+	for i, re := range patterns {
+		for _, loc := range re.FindAllStringIndex(s, -1) {
+			matched[i] = true
+			if loc[0] == loc[1] {
+				continue
+			}
+			spans = append(spans, [2]int{loc[0], loc[1]})
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:1]
+	for _, sp := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if sp[0] <= last[1] {
+			if sp[1] > last[1] {
+				last[1] = sp[1]
+			}
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// Search return Gomem that match query in title or lines, sorted by key
+// SearchRanked is sorted by score
+func (gs *Gomems) Search(query string, mode SearchMode) ([]*SearchResult, error) {
+	if mode == SearchRanked {
+		return gs.searchRanked(query)
+	}
+	patterns, err := compileQuery(query, mode)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := gs.Keys("key", false)
+	if err != nil {
+		return nil, err
+	}
+	var results []*SearchResult
+	for _, key := range keys {
+		if r := searchGomem(key, gs.Gmap[key], patterns); r != nil {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// searchGomem return nil if not all patterns matched
+func searchGomem(key string, g *Gomem, patterns []*regexp.Regexp) *SearchResult {
+	matched := make([]bool, len(patterns))
+	r := &SearchResult{Key: key, Title: g.J.Title}
+	r.TitleSpans = findSpans(g.J.Title, patterns, matched)
+	for i, line := range g.J.Lines() {
+		if spans := findSpans(line, patterns, matched); spans != nil {
+			r.Lines = append(r.Lines, LineMatch{Index: i, Text: line, Spans: spans})
+		}
+	}
+	for _, ok := range matched {
+		if !ok {
+			return nil
+		}
+	}
+	return r
+}
@@ -0,0 +1,60 @@
+package gomem
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGomems_Search(t *testing.T) {
+	gs := &Gomems{Gmap: map[string]*Gomem{
+		"a.json": {J: JSON{Title: "Shopping list", Content: []string{"milk", "Eggs and bread", "eggplant"}}},
+		"b.json": {J: JSON{Title: "meeting", Content: []string{"agenda: budget", "bring eggs"}}},
+		"todo/c.json": {J: JSON{Title: "chores", Todo: &Todo{Status: StatusOpen, Items: []Item{
+			{Text: "buy milk"}, {Text: "clean"},
+		}}}},
+	}}
+	// result format: key:title spans:[line index spans]...
+	format := func(results []*SearchResult) string {
+		var s string
+		for _, r := range results {
+			s += fmt.Sprintf("%s:%v:", r.Key, r.TitleSpans)
+			for _, l := range r.Lines {
+				s += fmt.Sprintf("%d%v", l.Index, l.Spans)
+			}
+			s += " "
+		}
+		return s
+	}
+	tests := []struct {
+		query   string
+		mode    SearchMode
+		want    string
+		wantErr bool
+	}{
+		{query: "EGG", mode: SearchSubstring, want: "a.json:[]:1[[0 3]]2[[0 3]] b.json:[]:1[[6 9]] "},
+		{query: "buy milk", mode: SearchSubstring, want: "todo/c.json:[]:0[[0 8]] "},
+		{query: "shop", mode: SearchSubstring, want: "a.json:[[0 4]]: "},
+		{query: `^egg\w+`, mode: SearchRegexp, want: "a.json:[]:2[[0 8]] "},
+		{query: "milk egg", mode: SearchAll, want: "a.json:[]:0[[0 4]]1[[0 3]]2[[0 3]] "},
+		{query: "milk clean", mode: SearchAll, want: "todo/c.json:[]:0[[4 8]]1[[0 5]] "},
+		{query: "milk meeting", mode: SearchAll, want: ""},
+		{query: "", mode: SearchSubstring, wantErr: true},
+		{query: "(", mode: SearchRegexp, wantErr: true},
+	}
+	for _, v := range tests {
+		results, err := gs.Search(v.query, v.mode)
+		if v.wantErr {
+			if err == nil {
+				t.Errorf("%q: want error", v.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", v.query, err)
+			continue
+		}
+		if got := format(results); got != v.want {
+			t.Errorf("%q mode %d:\n\twant %s\n\tgot  %s", v.query, v.mode, v.want, got)
+		}
+	}
+}
@@ -5,38 +5,122 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// LineReader read a line for Repl instead of SubCommands reader
+// e.g. lineedit.LineEditor
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// ErrInterrupt returned by LineReader to cancel line, e.g. by C-c
+var ErrInterrupt = errors.New("interrupt")
+
+// Handler command without argument, return result for Renderer
+type Handler func() (interface{}, error)
+
+// ArgHandler command with argument, return result for Renderer
+// argument is unquoted arguments joined by space
+type ArgHandler func(string) (interface{}, error)
+
 type subcmd struct {
-	f       func() (string, error)
-	fa      func(string) (string, error)
+	f       Handler
+	fa      ArgHandler
+	cmd     *Command // if not nil then used instead of f and fa
 	helpmsg string
 }
 
 // SubCommands interp functions for Repl
 type SubCommands struct {
-	r           io.Reader
-	w           io.Writer
-	Map         map[string]*subcmd
-	Prefix      string
-	InterCh     chan string  // accept another input
-	callBackCh  *chan string // callBackCh = &CallBackBuf
-	CallBackBuf chan string
+	r            io.Reader
+	w            io.Writer
+	Map          map[string]*subcmd
+	Prefix       string
+	InterCh      chan string  // accept another input
+	callBackCh   *chan string // callBackCh = &CallBackBuf
+	CallBackBuf  chan string
+	LineReader   LineReader                     // if not nil then used instead of reader
+	History      *History                       // if not nil then input is recorded and "!n" is expanded
+	ArgComplete  func(cmd, arg string) []string // candidates of argument for Complete
+	ScriptInput  func(io.Reader)                // if not nil then called with input of each command by RunScript
+	PromptWriter io.Writer                      // if not nil then prompts of ReadLine and errors of Repl are written to it instead of writer
+	format       string
+	renderers    map[string]Renderer
+	scriptDepth  int
+	sc           *bufio.Scanner // scanner of r, shared by Repl and ReadLine
 }
 
 // ErrValidExit for valid exit, for Repl
 var ErrValidExit = errors.New("valid exit")
 
+// ErrUnknownCommand returned by Run for command not in Map
+var ErrUnknownCommand = errors.New("unknown command")
+
+// CommandError failure of command
+// Repl prints result if not nil and it, then continues, Run returns it
+type CommandError struct {
+	Err error
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+// Fail return err as *CommandError
+func Fail(err error) error {
+	return &CommandError{Err: err}
+}
+
+// Failf return *CommandError of formatted message
+func Failf(format string, a ...interface{}) error {
+	return &CommandError{Err: fmt.Errorf(format, a...)}
+}
+
+// ReadLine read a line by LineReader, or by reader of sub after print prompt
+// for prompts of commands in Repl, input is shared with Repl
+// return io.EOF at end of input
+func (sub *SubCommands) ReadLine(prompt string) (string, error) {
+	if sub.LineReader != nil {
+		return sub.LineReader.ReadLine(prompt)
+	}
+	fmt.Fprint(sub.promptWriter(), prompt)
+	if sub.sc == nil {
+		sub.sc = bufio.NewScanner(sub.r)
+	}
+	if !sub.sc.Scan() {
+		if err := sub.sc.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return sub.sc.Text(), nil
+}
+
+// promptWriter return PromptWriter if not nil, else writer of sub
+func (sub *SubCommands) promptWriter() io.Writer {
+	if sub.PromptWriter != nil {
+		return sub.PromptWriter
+	}
+	return sub.w
+}
+
 // Repl is Read Eval Print Loop
 // call function in SubCommands[string]
 // string is from os.Stdin
 // if return ErrValidExit then return nil
+// line is split by SplitArgs before dispatch
+// results are written to writer, errors are written to PromptWriter if not nil
+// end of input, C-d of LineReader, runs "exit" for confirm of unsaved changes,
+// without LineReader end of input is once
 func (sub *SubCommands) Repl() error {
-	sc := bufio.NewScanner(sub.r)
 	done := false
+	eof := false
 	for {
 		var s string
+		typed := false // from user, not autocmd or callback
 		select {
 		case inter := <-sub.InterCh:
 			s = strings.TrimSpace(inter)
@@ -46,31 +130,79 @@ func (sub *SubCommands) Repl() error {
 			if done {
 				return nil
 			}
-			fmt.Fprint(sub.w, sub.Prefix)
-			if !sc.Scan() {
-				return fmt.Errorf("fail sc.Scan")
+			typed = true
+			line, err := sub.ReadLine(sub.Prefix)
+			if err == ErrInterrupt {
+				continue
 			}
-			if sc.Err() != nil {
-				return sc.Err()
+			if err == io.EOF {
+				if _, ok := sub.Map["exit"]; !ok {
+					return nil
+				}
+				if eof && sub.LineReader == nil {
+					return errors.New("end of input: exit is canceled")
+				}
+				eof = true
+				s, typed = "exit", false
+				break
+			}
+			if err != nil {
+				return err
+			}
+			s = strings.TrimSpace(line)
+		}
+		if sub.History != nil && typed {
+			line, expanded, err := sub.History.Expand(s)
+			if err != nil {
+				fmt.Fprintln(sub.promptWriter(), err)
+				continue
+			}
+			if expanded {
+				fmt.Fprintln(sub.promptWriter(), line)
+			}
+			s = line
+			if err := sub.History.Add(s); err != nil {
+				fmt.Fprintln(sub.promptWriter(), "history:", err)
 			}
-			s = strings.TrimSpace(sc.Text())
 		}
 
-		var result string
+		var result interface{}
 		var err error
-		cmdline := strings.SplitN(s, " ", 2)
-		cmd, ok := sub.Map[strings.TrimSpace(cmdline[0])]
+		// line is split once, quotes are same for all kinds of commands
+		tokens, terr := SplitArgs(s)
+		name := ""
+		if fields := strings.Fields(s); len(fields) != 0 {
+			name = fields[0]
+		}
+		if terr == nil && len(tokens) != 0 {
+			name = tokens[0]
+		}
+		cmd, ok := sub.Map[name]
 		if !ok {
-			fmt.Fprintf(sub.w, "invalid subcommand: %q\n", s)
+			fmt.Fprintf(sub.promptWriter(), "invalid subcommand: %q\n", s)
+			continue
+		}
+		if terr != nil {
+			if cmd.cmd != nil {
+				terr = &UsageError{Cmd: cmd.cmd, Msg: terr.Error()}
+			}
+			fmt.Fprintln(sub.promptWriter(), terr)
 			continue
 		}
 		switch {
-		case cmd.fa != nil && len(cmdline) == 2:
-			result, err = cmd.fa(strings.TrimSpace(cmdline[1]))
-		case cmd.f != nil && len(cmdline) == 1:
+		case cmd.cmd != nil:
+			args, perr := cmd.cmd.Parse(tokens[1:])
+			if perr != nil {
+				fmt.Fprintln(sub.promptWriter(), perr)
+				continue
+			}
+			result, err = cmd.cmd.Run(args)
+		case cmd.fa != nil && len(tokens) > 1:
+			result, err = cmd.fa(strings.Join(tokens[1:], " "))
+		case cmd.f != nil && len(tokens) == 1:
 			result, err = cmd.f()
 		default:
-			fmt.Fprintf(sub.w, "invalid subcommand: argument: %q\n", cmdline)
+			fmt.Fprintf(sub.promptWriter(), "invalid subcommand: argument: %q\n", tokens)
 			continue
 		}
 		if err != nil {
@@ -82,46 +214,163 @@ func (sub *SubCommands) Repl() error {
 					done = true
 					continue
 				}
-				fmt.Fprintln(sub.w, result) // exit message
+				sub.print(result) // exit message
 				return nil
 			default:
+				if _, ok := err.(*CommandError); ok {
+					if result != nil {
+						sub.print(result)
+					}
+					fmt.Fprintln(sub.promptWriter(), err)
+					continue
+				}
 				return err
 			}
 		}
-		fmt.Fprintln(sub.w, result)
+		sub.print(result)
 	}
 }
 
-// Addf append function
-func (sub *SubCommands) Addf(key string, fnc func() (string, error), help string) {
+// print render result to sub.w, empty result is not printed
+func (sub *SubCommands) print(result interface{}) {
+	s, err := sub.Render(result)
+	if err != nil {
+		fmt.Fprintln(sub.promptWriter(), "render:", err)
+		return
+	}
+	if s != "" {
+		fmt.Fprintln(sub.w, s)
+	}
+}
+
+// Run call command name with args without Repl, result is not rendered
+// args are passed to Command as is, joined by space for ArgHandler
+// return ErrUnknownCommand, *UsageError, *CommandError or error of command
+func (sub *SubCommands) Run(name string, args []string) (interface{}, error) {
+	cmd, ok := sub.Map[name]
+	if !ok {
+		return nil, ErrUnknownCommand
+	}
+	switch {
+	case cmd.cmd != nil:
+		a, err := cmd.cmd.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		return cmd.cmd.Run(a)
+	case cmd.fa != nil && len(args) != 0:
+		return cmd.fa(strings.Join(args, " "))
+	case cmd.f != nil && len(args) == 0:
+		return cmd.f()
+	case cmd.f != nil:
+		return nil, &UsageError{Cmd: &Command{Name: name}, Msg: "accept no arguments"}
+	}
+	return nil, &UsageError{Cmd: &Command{Name: name, Args: "<argument>"}, Msg: "require argument"}
+}
+
+// AddRenderer register renderer of format
+// text, json and ndjson are registered by SubNew
+func (sub *SubCommands) AddRenderer(format string, r Renderer) {
+	sub.renderers[format] = r
+}
+
+// Formats return sorted formats of registered renderers
+func (sub *SubCommands) Formats() []string {
+	return formatsOf(sub.renderers)
+}
+
+// Format return current format, default is text
+func (sub *SubCommands) Format() string {
+	return sub.format
+}
+
+// SetFormat change format of Render
+func (sub *SubCommands) SetFormat(format string) error {
+	if _, ok := sub.renderers[format]; !ok {
+		return fmt.Errorf("invalid format: %q: require %s", format, strings.Join(sub.Formats(), ", "))
+	}
+	sub.format = format
+	return nil
+}
+
+// Render render result of command by current format
+// nil is empty in all formats
+func (sub *SubCommands) Render(result interface{}) (string, error) {
+	if result == nil {
+		return "", nil
+	}
+	return sub.renderers[sub.format](result)
+}
+
+// Handle append command without argument
+func (sub *SubCommands) Handle(key string, h Handler, help string) {
 	if _, ok := sub.Map[key]; ok {
-		sub.Map[key].f = fnc
+		sub.Map[key].f = h
 		if sub.Map[key].helpmsg == "" {
 			sub.Map[key].helpmsg = help
 		}
 		return
 	}
 	sub.Map[key] = &subcmd{
-		f:       fnc,
+		f:       h,
 		helpmsg: help,
 	}
 }
 
-// Addfa append function with accept argument
-func (sub *SubCommands) Addfa(key string, fnc func(string) (string, error), help string) {
+// HandleArg append command with accept argument
+func (sub *SubCommands) HandleArg(key string, h ArgHandler, help string) {
 	if _, ok := sub.Map[key]; ok {
-		sub.Map[key].fa = fnc
+		sub.Map[key].fa = h
 		if sub.Map[key].helpmsg == "" {
 			sub.Map[key].helpmsg = help
 		}
 		return
 	}
 	sub.Map[key] = &subcmd{
-		fa:      fnc,
+		fa:      h,
 		helpmsg: help,
 	}
 }
 
+// Addf append function, result is Text
+func (sub *SubCommands) Addf(key string, fnc func() (string, error), help string) {
+	sub.Handle(key, func() (interface{}, error) {
+		return textResult(fnc())
+	}, help)
+}
+
+// Addfa append function with accept argument, result is Text
+func (sub *SubCommands) Addfa(key string, fnc func(string) (string, error), help string) {
+	sub.HandleArg(key, func(arg string) (interface{}, error) {
+		return textResult(fnc(arg))
+	}, help)
+}
+
+// textResult return s as Text, empty is nil
+func textResult(s string, err error) (interface{}, error) {
+	if s == "" {
+		return nil, err
+	}
+	return Text(s), err
+}
+
+// AddCommand append command that accept parsed arguments
+// invalid arguments are reported with usage of c
+// if c.Name exists then c is used instead of Addf and Addfa
+func (sub *SubCommands) AddCommand(c *Command) {
+	if _, ok := sub.Map[c.Name]; ok {
+		sub.Map[c.Name].cmd = c
+		if sub.Map[c.Name].helpmsg == "" {
+			sub.Map[c.Name].helpmsg = c.Help
+		}
+		return
+	}
+	sub.Map[c.Name] = &subcmd{
+		cmd:     c,
+		helpmsg: c.Help,
+	}
+}
+
 // SubNew return SubCommands
 func SubNew(r io.Reader, w io.Writer) *SubCommands {
 	mock := make(chan string)
@@ -132,6 +381,11 @@ func SubNew(r io.Reader, w io.Writer) *SubCommands {
 		InterCh:     make(chan string, 1),
 		CallBackBuf: make(chan string, 1),
 		callBackCh:  &mock,
+		format:      "text",
+		renderers:   make(map[string]Renderer),
+	}
+	for format, r := range Renderers {
+		sub.renderers[format] = r
 	}
 	return sub
 }
@@ -143,12 +397,69 @@ func (sub *SubCommands) Exit() (string, error) {
 	return "", ErrValidExit
 }
 
+// Keys return sorted keys of sub.Map
+func (sub *SubCommands) Keys() []string {
+	keys := make([]string, 0, len(sub.Map))
+	for key := range sub.Map {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Complete return candidates of line head for lineedit.LineEditor
+// command name is completed from sub.Map, argument is by sub.ArgComplete
+func (sub *SubCommands) Complete(head string) []string {
+	var candidates []string
+	i := strings.Index(head, " ")
+	if i == -1 {
+		for _, key := range sub.Keys() {
+			if strings.HasPrefix(key, head) {
+				candidates = append(candidates, key)
+			}
+		}
+		return candidates
+	}
+	cmd := head[:i]
+	if _, ok := sub.Map[cmd]; !ok || sub.ArgComplete == nil {
+		return nil
+	}
+	for _, arg := range sub.ArgComplete(cmd, strings.TrimLeft(head[i+1:], " ")) {
+		candidates = append(candidates, cmd+" "+arg)
+	}
+	return candidates
+}
+
+// ListHistory Base Commands for show sub.History
+func (sub *SubCommands) ListHistory() (string, error) {
+	if sub.History == nil {
+		return "history is disabled", nil
+	}
+	return strings.TrimSuffix(sub.History.String(), "\n"), nil
+}
+
+// ListHistoryN Base Commands for show last n lines of sub.History
+func (sub *SubCommands) ListHistoryN(s string) (string, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return "invalid number: " + s, nil
+	}
+	if sub.History == nil {
+		return "history is disabled", nil
+	}
+	return strings.TrimSuffix(sub.History.last(n), "\n"), nil
+}
+
 // Help Base Commands for show help message
+// sorted by command name
 func (sub *SubCommands) Help() (string, error) {
 	str := fmt.Sprintln("list commands:")
-	for key, v := range sub.Map {
+	for _, key := range sub.Keys() {
 		str += fmt.Sprintf("\t%s\n", key)
-		str += fmt.Sprintf("\t\t%s\n", v.helpmsg)
+		str += fmt.Sprintf("\t\t%s\n", sub.Map[key].helpmsg)
+		if c := sub.Map[key].cmd; c != nil {
+			str += "\t\t" + strings.Replace(c.Usage(), "\n", "\n\t\t", -1) + "\n"
+		}
 	}
 	return str, nil
 }
@@ -1,8 +1,352 @@
 package gomem
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestSimple(t *testing.T) {
 }
+
+func TestSubCommands_Help(t *testing.T) {
+	sub := SubNew(&bytes.Buffer{}, &bytes.Buffer{})
+	for _, key := range []string{"write", "exit", "ls", "la"} {
+		sub.Addf(key, sub.Exit, key+" help")
+	}
+	want := "list commands:\n" +
+		"\texit\n\t\texit help\n" +
+		"\tla\n\t\tla help\n" +
+		"\tls\n\t\tls help\n" +
+		"\twrite\n\t\twrite help\n"
+	for i := 0; i < 5; i++ {
+		got, err := sub.Help()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("want:\n%s\nbut got:\n%s", want, got)
+		}
+	}
+}
+
+func TestSubCommands_Complete(t *testing.T) {
+	sub := SubNew(&bytes.Buffer{}, &bytes.Buffer{})
+	for _, key := range []string{"show", "search", "state", "exit"} {
+		sub.Addfa(key, func(string) (string, error) { return "", nil }, "")
+	}
+	sub.ArgComplete = func(cmd, arg string) []string {
+		if cmd != "show" {
+			return nil
+		}
+		return []string{arg + "a.json", arg + "b.json"}
+	}
+	tests := []struct {
+		head string
+		want []string
+	}{
+		{head: "s", want: []string{"search", "show", "state"}},
+		{head: "se", want: []string{"search"}},
+		{head: "", want: []string{"exit", "search", "show", "state"}},
+		{head: "show x", want: []string{"show xa.json", "show xb.json"}},
+		{head: "state x", want: nil},
+		{head: "nothing x", want: nil},
+	}
+	for _, v := range tests {
+		if got := sub.Complete(v.head); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%q: want %q but got %q", v.head, v.want, got)
+		}
+	}
+}
+
+// lineReader mock of LineReader
+type lineReader []string
+
+func (lr *lineReader) ReadLine(prompt string) (string, error) {
+	if len(*lr) == 0 {
+		return "", io.EOF
+	}
+	line := (*lr)[0]
+	*lr = (*lr)[1:]
+	if line == "^C" {
+		return "", ErrInterrupt
+	}
+	return line, nil
+}
+
+func TestSubCommands_ReplLineReader(t *testing.T) {
+	out := &bytes.Buffer{}
+	sub := SubNew(&bytes.Buffer{}, out)
+	sub.Addfa("echo", func(s string) (string, error) { return s, nil }, "")
+	sub.Addf("exit", sub.Exit, "")
+	sub.LineReader = &lineReader{"echo hello", "^C", "echo world", "exit"}
+	if err := sub.Repl(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello\nworld\n"; out.String() != want {
+		t.Errorf("want %q but got %q", want, out.String())
+	}
+}
+
+func TestSubCommands_ReplHistory(t *testing.T) {
+	out := &bytes.Buffer{}
+	sub := SubNew(&bytes.Buffer{}, out)
+	sub.Addfa("echo", func(s string) (string, error) { return s, nil }, "")
+	sub.Addf("exit", sub.Exit, "")
+	sub.Addf("history", sub.ListHistory, "")
+	sub.History = &History{}
+	sub.InterCh <- "echo autocmd"
+	sub.LineReader = &lineReader{"echo hello", "!1", "!9", "history", "exit"}
+	if err := sub.Repl(); err != nil {
+		t.Fatal(err)
+	}
+	want := "autocmd\nhello\necho hello\nhello\ninvalid history number: !9\n" +
+		"    1  echo hello\n    2  history\n"
+	if out.String() != want {
+		t.Errorf("want %q but got %q", want, out.String())
+	}
+}
+
+func TestSubCommands_AddCommand(t *testing.T) {
+	out := &bytes.Buffer{}
+	sub := SubNew(&bytes.Buffer{}, out)
+	sub.Addf("exit", sub.Exit, "")
+	sub.AddCommand(&Command{
+		Name: "join", Args: "<word>...", MinArgs: 1, MaxArgs: -1,
+		Flags: []Flag{{Name: "sep", Arg: "s"}},
+		Run: func(a *Args) (interface{}, error) {
+			sep := " "
+			if a.Has("sep") {
+				sep = a.Value("sep")
+			}
+			return strings.Join(a.Args, sep), nil
+		},
+	})
+	sub.LineReader = &lineReader{`join "a b" c --sep ,`, "join", `join "x`, "exit"}
+	if err := sub.Repl(); err != nil {
+		t.Fatal(err)
+	}
+	want := "a b,c\n" +
+		"require 1 arguments but got 0\nusage: join [flags] <word>...\n\t--sep s\t\n" +
+		"unterminated quote: \"x\nusage: join [flags] <word>...\n\t--sep s\t\n"
+	if out.String() != want {
+		t.Errorf("want %q but got %q", want, out.String())
+	}
+}
+
+func TestSubCommands_Run(t *testing.T) {
+	sub := SubNew(&bytes.Buffer{}, &bytes.Buffer{})
+	sub.Addf("exit", sub.Exit, "")
+	sub.Addf("ls", func() (string, error) { return "list", nil }, "")
+	sub.Addfa("show", func(s string) (string, error) {
+		if s == "missing" {
+			return "", Failf("not found:%s", s)
+		}
+		return "show:" + s, nil
+	}, "")
+	sub.AddCommand(&Command{
+		Name: "count", MinArgs: 1, MaxArgs: -1,
+		Run: func(a *Args) (interface{}, error) { return len(a.Args), nil },
+	})
+	tests := []struct {
+		name    string
+		args    []string
+		want    interface{}
+		wantErr string // type of error
+	}{
+		{name: "ls", want: Text("list")},
+		{name: "show", args: []string{"todo/x", "y"}, want: Text("show:todo/x y")},
+		{name: "count", args: []string{"a b", "c"}, want: 2},
+		{name: "exit", wantErr: "exit"},
+		{name: "show", args: []string{"missing"}, wantErr: "fail"},
+		{name: "count", wantErr: "usage"},
+		{name: "ls", args: []string{"x"}, wantErr: "usage"},
+		{name: "show", wantErr: "usage"},
+		{name: "nothing", wantErr: "unknown"},
+	}
+	for _, v := range tests {
+		got, err := sub.Run(v.name, v.args)
+		var kind string
+		switch err.(type) {
+		case nil:
+		case *CommandError:
+			kind = "fail"
+		case *UsageError:
+			kind = "usage"
+		default:
+			switch err {
+			case ErrValidExit:
+				kind = "exit"
+			case ErrUnknownCommand:
+				kind = "unknown"
+			default:
+				kind = err.Error()
+			}
+		}
+		if kind != v.wantErr || got != v.want {
+			t.Errorf("%s %q: want %#v %q but got %#v %q", v.name, v.args, v.want, v.wantErr, got, kind)
+		}
+	}
+}
+
+func TestSubCommands_ReplCommandError(t *testing.T) {
+	out := &bytes.Buffer{}
+	sub := SubNew(&bytes.Buffer{}, out)
+	sub.Addf("exit", sub.Exit, "")
+	sub.Addf("fail", func() (string, error) { return "", Failf("failed") }, "")
+	sub.LineReader = &lineReader{"fail", "exit"}
+	if err := sub.Repl(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "failed\n"; out.String() != want {
+		t.Errorf("want %q but got %q", want, out.String())
+	}
+}
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (p point) String() string {
+	return fmt.Sprintf("(%d, %d)", p.X, p.Y)
+}
+
+func TestSubCommands_Render(t *testing.T) {
+	out := &bytes.Buffer{}
+	sub := SubNew(&bytes.Buffer{}, out)
+	sub.Addf("exit", sub.Exit, "")
+	sub.Addf("hello", func() (string, error) { return "hello", nil }, "")
+	sub.Handle("points", func() (interface{}, error) {
+		return []point{{1, 2}, {3, 4}}, nil
+	}, "")
+	sub.HandleArg("format", func(s string) (interface{}, error) {
+		if err := sub.SetFormat(s); err != nil {
+			return nil, Fail(err)
+		}
+		return nil, nil
+	}, "")
+	sub.AddRenderer("upper", func(v interface{}) (string, error) {
+		s, err := RenderText(v)
+		return strings.ToUpper(s), err
+	})
+	sub.LineReader = &lineReader{
+		"points", "hello",
+		"format ndjson", "points", "hello",
+		"format json", "hello",
+		"format upper", "hello",
+		"format xml", "exit",
+	}
+	if err := sub.Repl(); err != nil {
+		t.Fatal(err)
+	}
+	want := "[(1, 2) (3, 4)]\nhello\n" +
+		`{"x":1,"y":2}` + "\n" + `{"x":3,"y":4}` + "\n" + `{"message":"hello"}` + "\n" +
+		"{\n  \"message\": \"hello\"\n}\n" +
+		"HELLO\n" +
+		"invalid format: \"xml\": require json, ndjson, text, upper\n"
+	if out.String() != want {
+		t.Errorf("want %q but got %q", want, out.String())
+	}
+	if sub.Format() != "upper" {
+		t.Errorf("want upper but got %s", sub.Format())
+	}
+}
+
+func TestSubCommands_ReplEOF(t *testing.T) {
+	// C-d of LineReader runs exit
+	out := &bytes.Buffer{}
+	sub := SubNew(&bytes.Buffer{}, out)
+	sub.Handle("exit", func() (interface{}, error) { return "bye", ErrValidExit }, "")
+	sub.LineReader = &lineReader{}
+	if err := sub.Repl(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "bye\n" {
+		t.Errorf("want exit by EOF but got %q", out.String())
+	}
+
+	// end of input runs exit once, canceled exit stops Repl
+	exits := 0
+	sub = SubNew(strings.NewReader("echo a\n"), out)
+	sub.Addfa("echo", func(s string) (string, error) { return s, nil }, "")
+	sub.Handle("exit", func() (interface{}, error) { exits++; return nil, nil }, "")
+	if err := sub.Repl(); err == nil || exits != 1 {
+		t.Errorf("want error after one exit but got %v, exits %d", err, exits)
+	}
+}
+
+func TestSubCommands_ReadLine(t *testing.T) {
+	// prompt of command reads following line of Repl input
+	out := &bytes.Buffer{}
+	sub := SubNew(strings.NewReader("ask\nanswer\nexit\n"), out)
+	sub.Addf("exit", sub.Exit, "")
+	sub.Addf("ask", func() (string, error) {
+		s, err := sub.ReadLine("question> ")
+		return "got " + s, err
+	}, "")
+	if err := sub.Repl(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "question> got answer\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("want %q in %q", want, out.String())
+	}
+
+	// prompts are written to PromptWriter
+	out.Reset()
+	prompts := &bytes.Buffer{}
+	sub = SubNew(strings.NewReader("answer\n"), out)
+	sub.PromptWriter = prompts
+	if s, err := sub.ReadLine("question> "); err != nil || s != "answer" {
+		t.Errorf("want answer but got %q %v", s, err)
+	}
+	if out.String() != "" || prompts.String() != "question> " {
+		t.Errorf("want prompt in PromptWriter but got %q and %q", out.String(), prompts.String())
+	}
+}
+
+func TestSubCommands_ReplQuote(t *testing.T) {
+	out := &bytes.Buffer{}
+	sub := SubNew(&bytes.Buffer{}, out)
+	sub.Addf("exit", sub.Exit, "")
+	sub.Addf("ls", func() (string, error) { return "list", nil }, "")
+	sub.Addfa("show", func(s string) (string, error) { return "[" + s + "]", nil }, "")
+	sub.LineReader = &lineReader{
+		"show\ttodo/x", `show "my key"`, `show 'a  b' c`, "ls\t", `show "x`, "exit",
+	}
+	if err := sub.Repl(); err != nil {
+		t.Fatal(err)
+	}
+	want := "[todo/x]\n[my key]\n[a  b c]\nlist\nunterminated quote: \"x\n"
+	if out.String() != want {
+		t.Errorf("want %q but got %q", want, out.String())
+	}
+}
+
+func TestSubCommands_ReplPromptWriter(t *testing.T) {
+	// results only are written to writer
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	sub := SubNew(&bytes.Buffer{}, out)
+	sub.PromptWriter = errOut
+	sub.Addf("exit", sub.Exit, "")
+	sub.Addf("hello", func() (string, error) { return "hello", nil }, "")
+	sub.Addf("fail", func() (string, error) { return "", Failf("failed") }, "")
+	sub.Handle("none", func() (interface{}, error) { return nil, nil }, "")
+	if err := sub.SetFormat("ndjson"); err != nil {
+		t.Fatal(err)
+	}
+	sub.LineReader = &lineReader{"hello", "nothing", "fail", "none", "hello x", "exit"}
+	if err := sub.Repl(); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"message":"hello"}` + "\n"; out.String() != want {
+		t.Errorf("want %q but got %q", want, out.String())
+	}
+	want := "invalid subcommand: \"nothing\"\nfailed\ninvalid subcommand: argument: [\"hello\" \"x\"]\n"
+	if errOut.String() != want {
+		t.Errorf("want %q but got %q", want, errOut.String())
+	}
+}
@@ -0,0 +1,214 @@
+package gomem
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Status of Todo
+type Status string
+
+// Status values
+const (
+	StatusOpen       Status = "open"
+	StatusInProgress Status = "in-progress"
+	StatusDone       Status = "done"
+	StatusCancelled  Status = "cancelled"
+)
+
+// ParseStatus return Status from string
+func ParseStatus(s string) (Status, error) {
+	switch st := Status(s); st {
+	case StatusOpen, StatusInProgress, StatusDone, StatusCancelled:
+		return st, nil
+	}
+	return "", fmt.Errorf("invalid status %q: require open, in-progress, done or cancelled", s)
+}
+
+// IsClosed return true if done or cancelled
+func (st Status) IsClosed() bool {
+	return st == StatusDone || st == StatusCancelled
+}
+
+// Item checklist item of Todo
+type Item struct {
+	Text string `json:"text"`
+	Done bool   `json:"done,omitempty"`
+}
+
+// Todo todo structure in JSON
+// created time is JSON.Created
+type Todo struct {
+	Status    Status       `json:"status"`
+	Priority  Priority     `json:"priority,omitempty"`
+	Completed *time.Time   `json:"completed,omitempty"` // time of done or cancelled
+	Due       *time.Time   `json:"due,omitempty"`
+	Remind    *time.Time   `json:"remind,omitempty"`
+	Recur     *Recurrence  `json:"recur,omitempty"`
+	History   []Completion `json:"history,omitempty"` // completions of recurring todo
+	Items     []Item       `json:"items,omitempty"`
+}
+
+// NewTodo return *Gomem as open todo
+func NewTodo(fpath string) (*Gomem, error) {
+	g, err := New(fpath, true)
+	if err != nil {
+		return nil, err
+	}
+	g.J.Todo = &Todo{Status: StatusOpen}
+	return g, nil
+}
+
+// Lines return text lines of j
+// for todo return text of items
+func (j *JSON) Lines() []string {
+	if j.Todo == nil {
+		return j.Content
+	}
+	lines := make([]string, 0, len(j.Todo.Items))
+	for _, item := range j.Todo.Items {
+		lines = append(lines, item.Text)
+	}
+	return lines
+}
+
+// IsTodo return true if g.J.Todo is not nil
+func (g *Gomem) IsTodo() bool {
+	return g.J.Todo != nil
+}
+
+// errNotTodo for todo methods
+func (g *Gomem) errNotTodo() error {
+	return fmt.Errorf("%s is not todo", filepath.Base(g.fullpath))
+}
+
+// SetStatus set status of todo
+// set Completed when closed, clear when reopened
+// done of recurring todo records history and moves due to next date, status is kept
+func (g *Gomem) SetStatus(st Status) error {
+	if !g.IsTodo() {
+		return g.errNotTodo()
+	}
+	if _, err := ParseStatus(string(st)); err != nil {
+		return err
+	}
+	if st == StatusDone && g.J.Todo.Recur != nil && !g.J.Todo.Status.IsClosed() {
+		if err := g.J.Todo.rollRecur(time.Now()); err != nil {
+			return err
+		}
+		g.SetDirty()
+		return nil
+	}
+	if g.J.Todo.Status == st {
+		return nil
+	}
+	g.J.Todo.Status = st
+	if st.IsClosed() {
+		now := time.Now()
+		g.J.Todo.Completed = &now
+	} else {
+		g.J.Todo.Completed = nil
+	}
+	g.SetDirty()
+	return nil
+}
+
+// AddItem append checklist item
+// if todo is closed then reopen
+func (g *Gomem) AddItem(text string) error {
+	if !g.IsTodo() {
+		return g.errNotTodo()
+	}
+	g.J.Todo.Items = append(g.J.Todo.Items, Item{Text: text})
+	if g.J.Todo.Status.IsClosed() {
+		g.J.Todo.Status = StatusOpen
+		g.J.Todo.Completed = nil
+	}
+	g.SetDirty()
+	return nil
+}
+
+// RemoveItem remove item of index i, i is from 0
+func (g *Gomem) RemoveItem(i int) error {
+	if !g.IsTodo() {
+		return g.errNotTodo()
+	}
+	if i < 0 || i >= len(g.J.Todo.Items) {
+		return fmt.Errorf("invalid item index: %d", i+1)
+	}
+	g.J.Todo.Items = append(g.J.Todo.Items[:i], g.J.Todo.Items[i+1:]...)
+	g.SetDirty()
+	return nil
+}
+
+// ToggleItem toggle done of item of index i, i is from 0
+func (g *Gomem) ToggleItem(i int) error {
+	if !g.IsTodo() {
+		return g.errNotTodo()
+	}
+	if i < 0 || i >= len(g.J.Todo.Items) {
+		return fmt.Errorf("invalid item index: %d", i+1)
+	}
+	g.J.Todo.Items[i].Done = !g.J.Todo.Items[i].Done
+	g.SetDirty()
+	return nil
+}
+
+// legacyTodoTitle title format of todo before schema version 2
+// "<2006 January 2 15:4>:todo/name.json" with ":done" suffix if done
+var legacyTodoTitle = regexp.MustCompile(`^<(\d+ [A-Za-z]+ \d+ \d+:\d+)>:todo[/\\](.+?)(:done)?$`)
+
+// isTodoKey return true if key is under todo directory
+func isTodoKey(key string) bool {
+	return strings.HasPrefix(filepath.ToSlash(key), "todo/")
+}
+
+func init() {
+	RegisterMigration(Migration{
+		From:        1,
+		Description: "convert memo under todo/ and \"<time>:todo/name:done\" title to todo status and items",
+		Migrate: func(key string, doc map[string]interface{}) error {
+			title, _ := doc["title"].(string)
+			m := legacyTodoTitle.FindStringSubmatch(title)
+			if m == nil && !isTodoKey(key) {
+				return nil
+			}
+			todo := map[string]interface{}{"status": StatusOpen}
+			if m != nil {
+				title = strings.TrimSuffix(m[2], ".json")
+				if m[3] != "" {
+					todo["status"] = StatusDone
+				}
+				if t, err := time.ParseInLocation("2006 January 2 15:4", m[1], time.Local); err == nil {
+					if _, ok := doc["created"]; !ok {
+						doc["created"] = t
+					}
+				}
+			} else {
+				// title edited by hand
+				if strings.HasSuffix(title, ":done") {
+					title = strings.TrimSuffix(title, ":done")
+					todo["status"] = StatusDone
+				}
+				if strings.TrimSpace(title) == "" {
+					title = strings.TrimSuffix(filepath.Base(key), ".json")
+				}
+			}
+			var items []interface{}
+			content, _ := doc["content"].([]interface{})
+			for _, line := range content {
+				items = append(items, map[string]interface{}{"text": line})
+			}
+			if items != nil {
+				todo["items"] = items
+			}
+			doc["todo"] = todo
+			doc["title"] = title
+			delete(doc, "content")
+			return nil
+		},
+	})
+}
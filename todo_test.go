@@ -0,0 +1,173 @@
+package gomem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGomem_Todo(t *testing.T) {
+	g, err := NewTodo(filepath.Join(tmpdir, "todo.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.IsTodo() || g.J.Todo.Status != StatusOpen {
+		t.Fatalf("want open todo: %+v", g.J.Todo)
+	}
+	for _, text := range []string{"a", "b", "c"} {
+		if err := g.AddItem(text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.RemoveItem(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RemoveItem(2); err == nil {
+		t.Error("want error for out of range")
+	}
+	if err := g.ToggleItem(1); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(g.J.Todo.Items) != "[{a false} {c true}]" {
+		t.Errorf("unexpected items: %v", g.J.Todo.Items)
+	}
+	if fmt.Sprint(g.J.Lines()) != "[a c]" {
+		t.Errorf("unexpected lines: %v", g.J.Lines())
+	}
+
+	if err := g.SetStatus(StatusDone); err != nil {
+		t.Fatal(err)
+	}
+	if g.J.Todo.Completed == nil {
+		t.Error("want completed time")
+	}
+	if err := g.SetStatus(Status("later")); err == nil {
+		t.Error("want error for invalid status")
+	}
+	// append reopen
+	if err := g.AddItem("d"); err != nil {
+		t.Fatal(err)
+	}
+	if g.J.Todo.Status != StatusOpen || g.J.Todo.Completed != nil {
+		t.Errorf("want reopen by AddItem: %+v", g.J.Todo)
+	}
+
+	plain, err := New(filepath.Join(tmpdir, "plain.json"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.AddItem("a"); err == nil {
+		t.Error("want error for not todo")
+	}
+}
+
+func TestMigration_LegacyTodo(t *testing.T) {
+	tests := []struct {
+		key        string
+		in         string
+		wantTitle  string
+		wantStatus Status
+		wantItems  string
+	}{
+		{
+			in:         `{"title": "<2018 March 3 9:5>:todo/shopping.json", "content": ["milk", "egg"]}`,
+			wantTitle:  "shopping",
+			wantStatus: StatusOpen,
+			wantItems:  "[{milk false} {egg false}]",
+		},
+		{
+			in:         `{"title": "<2018 March 3 12:45>:todo/x.json:done", "content": ["a"]}`,
+			wantTitle:  "x",
+			wantStatus: StatusDone,
+			wantItems:  "[{a false}]",
+		},
+		{
+			// title edited by hand
+			key:        filepath.Join("todo", "milk.json"),
+			in:         `{"title": "buy milk:done", "content": ["a"]}`,
+			wantTitle:  "buy milk",
+			wantStatus: StatusDone,
+			wantItems:  "[{a false}]",
+		},
+		{
+			// made by new todo/x
+			key:        filepath.Join("todo", "sub", "x.json"),
+			in:         `{"title": "", "content": ["a"]}`,
+			wantTitle:  "x",
+			wantStatus: StatusOpen,
+			wantItems:  "[{a false}]",
+		},
+		{
+			// not todo
+			key:       "meeting.json",
+			in:        `{"title": "meeting:done", "content": ["a"]}`,
+			wantTitle: "meeting:done",
+		},
+	}
+	for _, v := range tests {
+		j, _, err := decodeJSON([]byte(v.in), v.key)
+		if err != nil {
+			t.Errorf("%s: %v", v.in, err)
+			continue
+		}
+		if j.Title != v.wantTitle {
+			t.Errorf("want title %q but got %q", v.wantTitle, j.Title)
+		}
+		if v.wantStatus == "" {
+			if j.Todo != nil || len(j.Content) != 1 {
+				t.Errorf("%s: unexpected migrated: %+v", v.in, j)
+			}
+			continue
+		}
+		if j.Todo == nil {
+			t.Errorf("%s: not migrated to todo", v.in)
+			continue
+		}
+		if j.Todo.Status != v.wantStatus || fmt.Sprint(j.Todo.Items) != v.wantItems || j.Content != nil {
+			t.Errorf("%s: unexpected todo: %+v %v", v.in, j.Todo, j.Content)
+		}
+		if v.key == "" && (j.Created == nil || j.Created.Year() != 2018) {
+			t.Errorf("%s: created is not migrated: %v", v.in, j.Created)
+		}
+	}
+}
+
+func TestGomemsNew_LegacyTodoByKey(t *testing.T) {
+	dir, err := ioutil.TempDir(tmpdir, "legacytodo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "todo"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join("todo", "edited.json"): `{"title": "edited title:done", "content": ["a"]}`,
+		"memo.json":                          `{"title": "memo:done", "content": ["a"]}`,
+	}
+	for key, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, key), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gs, err := GomemsNew(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gs.Close()
+	g := gs.Gmap[filepath.Join("todo", "edited.json")]
+	if !g.IsTodo() || g.J.Todo.Status != StatusDone || g.J.Title != "edited title" {
+		t.Errorf("not migrated to todo: %+v", g.J)
+	}
+	if gs.Gmap["memo.json"].IsTodo() {
+		t.Error("memo out of todo is migrated")
+	}
+	keys, err := gs.SortTodos("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != filepath.Join("todo", "edited.json") {
+		t.Errorf("want edited todo in list but got %q", keys)
+	}
+}